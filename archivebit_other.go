@@ -0,0 +1,9 @@
+//go:build !windows
+
+package gopfs0
+
+// setArchiveBit does nothing, as there is no portable way to set the FAT
+// archive attribute outside Windows
+func setArchiveBit(path string) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package gopfs0
+
+import "syscall"
+
+// setArchiveBit sets the archive attribute on path
+func setArchiveBit(path string) error {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	attrs, err := syscall.GetFileAttributes(p)
+	if err != nil {
+		return err
+	}
+	return syscall.SetFileAttributes(p, attrs|syscall.FILE_ATTRIBUTE_ARCHIVE)
+}
@@ -0,0 +1,131 @@
+package gopfs0
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CNMTFile returns the index of the content meta file, which is either a
+// .cnmt.nca or a loose .cnmt
+func (p *PFS0) CNMTFile() (uint16, File, error) {
+	for i, f := range p.Files {
+		if strings.HasSuffix(f.Name, ".cnmt.nca") || strings.HasSuffix(f.Name, ".cnmt") {
+			return uint16(i), f, nil
+		}
+	}
+	return 0, File{}, errors.New("No CNMT found in PFS0")
+}
+
+// ReadCNMT returns the content of the .cnmt file in the PFS0. A loose .cnmt is
+// read directly. A .cnmt.nca can only be read when it has been decrypted, in
+// which case the .cnmt is read from the PFS0 in its first section; otherwise
+// ErrEncrypted is returned.
+func (p *PFS0) ReadCNMT() ([]byte, error) {
+	ind, f, err := p.CNMTFile()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(f.Name, ".nca") {
+		return p.readFile(ind)
+	}
+
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, err
+	}
+	if size < ncaHeaderSize {
+		return nil, fmt.Errorf("%w: %s is smaller than an NCA header", ErrTruncated, f.Name)
+	}
+	hdr, err := p.readAt(offset, ncaHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	pfs0Offset, pfs0Size, err := ncaSectionPFS0(hdr, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %w", f.Name, err)
+	}
+	if pfs0Offset+pfs0Size > size {
+		return nil, fmt.Errorf("%w: PFS0 extends past end of %s", ErrTruncated, f.Name)
+	}
+
+	inner, err := p.nestedAt(f.Name, offset+pfs0Offset, pfs0Size)
+	if err != nil {
+		return nil, err
+	}
+	for i, innerFile := range inner.Files {
+		if strings.HasSuffix(innerFile.Name, ".cnmt") {
+			return inner.readFile(uint16(i))
+		}
+	}
+	return nil, fmt.Errorf("No .cnmt found inside %s", f.Name)
+}
+
+const (
+	cnmtExtHeaderSize = 0xE
+	cnmtContentCount  = 0x10
+	cnmtHeaderSize    = 0x20
+	cnmtRecordSize    = 0x38
+	// Offset of the content ID within a content record, after its SHA-256
+	cnmtRecordID = 0x20
+)
+
+// cnmtContentIDs returns the lower case hex content IDs of the content
+// records in cnmt
+func cnmtContentIDs(cnmt []byte) ([]string, error) {
+	if len(cnmt) < cnmtHeaderSize {
+		return nil, fmt.Errorf("%w: CNMT is only 0x%X bytes", ErrTruncated, len(cnmt))
+	}
+	start := cnmtHeaderSize + int(binary.LittleEndian.Uint16(cnmt[cnmtExtHeaderSize:]))
+	count := int(binary.LittleEndian.Uint16(cnmt[cnmtContentCount:]))
+	if len(cnmt) < start+count*cnmtRecordSize {
+		return nil, fmt.Errorf("%w: CNMT lists %d content records but is only 0x%X bytes", ErrTruncated, count, len(cnmt))
+	}
+	ids := make([]string, count)
+	for i := range ids {
+		record := cnmt[start+i*cnmtRecordSize:]
+		ids[i] = hex.EncodeToString(record[cnmtRecordID : cnmtRecordID+0x10])
+	}
+	return ids, nil
+}
+
+// VerifyContentRecords compares the content records of the CNMT with the NCAs
+// in the PFS0 by content ID. missing lists the content IDs of records with no
+// matching NCA, as in an incomplete dump, and extraneous lists NCAs that no
+// record mentions. The content meta NCA itself is not a record and is never
+// reported.
+func (p *PFS0) VerifyContentRecords() (missing, extraneous []string, err error) {
+	cnmt, err := p.ReadCNMT()
+	if err != nil {
+		return nil, nil, err
+	}
+	ids, err := cnmtContentIDs(cnmt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+	present := make(map[string]bool)
+	for _, f := range p.Files {
+		ext := fileExtension(f.Name)
+		if ext != "nca" && ext != "ncz" {
+			continue
+		}
+		id := strings.ToLower(strings.Split(f.Name, ".")[0])
+		present[id] = true
+		if !listed[id] {
+			extraneous = append(extraneous, f.Name)
+		}
+	}
+	for _, id := range ids {
+		if !present[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, extraneous, nil
+}
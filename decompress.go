@@ -0,0 +1,60 @@
+package gopfs0
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Decompressor creates readers that decompress a stream of one format
+type Decompressor interface {
+	NewReader(r io.Reader) (io.Reader, error)
+}
+
+// DecompressorFunc adapts a function to Decompressor
+type DecompressorFunc func(r io.Reader) (io.Reader, error)
+
+// NewReader calls f(r)
+func (f DecompressorFunc) NewReader(r io.Reader) (io.Reader, error) {
+	return f(r)
+}
+
+// ErrNoDecompressor is returned when data uses a compression format that has
+// no registered Decompressor
+var ErrNoDecompressor = errors.New("No decompressor registered")
+
+var (
+	decompressorsMu sync.RWMutex
+	// decompressors maps a lower case format name, such as "zstd", to its
+	// Decompressor. gzip is always available; other formats such as the zstd
+	// used by NSZ are left to callers so the package does not depend on a
+	// particular implementation.
+	decompressors = map[string]Decompressor{
+		"gzip": DecompressorFunc(func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		}),
+	}
+)
+
+// RegisterDecompressor makes d available for the named format, replacing any
+// Decompressor already registered for it. Names are not case sensitive.
+func RegisterDecompressor(format string, d Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	decompressors[strings.ToLower(format)] = d
+}
+
+// NewDecompressReader returns a reader decompressing r with the Decompressor
+// registered for format, or an error wrapping ErrNoDecompressor
+func NewDecompressReader(format string, r io.Reader) (io.Reader, error) {
+	decompressorsMu.RLock()
+	d, ok := decompressors[strings.ToLower(format)]
+	decompressorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoDecompressor, format)
+	}
+	return d.NewReader(r)
+}
@@ -0,0 +1,726 @@
+package gopfs0
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WriteFileTo copies the file with the given index to w. If the source ends
+// before the file's Size bytes are copied an error wrapping ErrTruncated is
+// returned along with the number of bytes that were copied.
+func (p *PFS0) WriteFileTo(ind uint16, w io.Writer) (int64, error) {
+	return p.writeFileTo(ind, w, nil)
+}
+
+// writeFileTo implements WriteFileTo, copying through buf when it is not nil
+// so a loop over many files can reuse one buffer
+func (p *PFS0) writeFileTo(ind uint16, w io.Writer, buf []byte) (int64, error) {
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return 0, err
+	}
+
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return 0, err
+	}
+	defer closeFile()
+
+	var r io.Reader
+	if buf != nil {
+		r = p.sequentialReaderWith(fileHandle, offset, size, buf)
+	} else {
+		var release func()
+		r, release = p.sequentialReader(fileHandle, offset, size)
+		defer release()
+	}
+	return copyExact(w, r, size, p.Files[ind].Name)
+}
+
+// ExtractToPipe returns a reader that streams the file with the given index,
+// for feeding it to another program such as through exec.Cmd.Stdin. The file
+// is copied by a goroutine as the reader is drained, so reads block until
+// data is available. Read returns any copy error, including ErrTruncated,
+// once the data before it has been read. Close the reader to stop early.
+func (p *PFS0) ExtractToPipe(ind uint16) (io.ReadCloser, error) {
+	if _, _, err := p.fileRegion(ind); err != nil {
+		return nil, err
+	}
+	r, w := io.Pipe()
+	go func() {
+		_, err := p.WriteFileTo(ind, w)
+		w.CloseWithError(err)
+	}()
+	return r, nil
+}
+
+// WriteFileToStdout copies the file with the given index to standard output,
+// for command line tools whose output is piped into another program
+func (p *PFS0) WriteFileToStdout(ind uint16) (int64, error) {
+	return p.WriteFileTo(ind, os.Stdout)
+}
+
+// CopyFileRange copies bytes from up to but not including to of the file with
+// the given index to w, failing with ErrTruncated like WriteFileTo
+func (p *PFS0) CopyFileRange(ind uint16, w io.Writer, from, to int64) (int64, error) {
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return 0, err
+	}
+	if from < 0 || to < from || uint64(to) > size {
+		return 0, fmt.Errorf("Invalid range %d-%d for %s of size %d", from, to, p.Files[ind].Name, size)
+	}
+
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return 0, err
+	}
+	defer closeFile()
+
+	r, release := p.sequentialReader(fileHandle, offset+uint64(from), uint64(to-from))
+	defer release()
+	return copyExact(w, r, uint64(to-from), p.Files[ind].Name)
+}
+
+// copyExact copies r to w, failing with ErrTruncated unless exactly size bytes
+// were copied
+func copyExact(w io.Writer, r io.Reader, size uint64, name string) (int64, error) {
+	n, err := io.Copy(w, r)
+	if err == nil && uint64(n) != size {
+		err = fmt.Errorf("%w: expected 0x%X bytes of %s, copied 0x%X", ErrTruncated, size, name, n)
+	}
+	return n, err
+}
+
+// ExtractFileLimit writes at most maxBytes from the start of the file with the
+// given index to destPath and returns how many bytes were written
+func (p *PFS0) ExtractFileLimit(ind uint16, destPath string, maxBytes int64) (int64, error) {
+	if int(ind) >= len(p.Files) {
+		return 0, p.invalid(fmt.Errorf("File index %d out of range", ind))
+	}
+	if maxBytes < 0 {
+		return 0, fmt.Errorf("Invalid byte limit %d", maxBytes)
+	}
+	to := maxBytes
+	if size := p.Files[ind].Size; uint64(to) > size {
+		to = int64(size)
+	}
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	n, err := p.CopyFileRange(ind, out, 0, to)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// ExtractFile writes the file with the given index to destPath
+func (p *PFS0) ExtractFile(ind uint16, destPath string) error {
+	return p.extractTo(ind, destPath, nil)
+}
+
+// ExtractFirst writes the first file for which pred returns true to destPath
+// and returns it. An error wrapping ErrNoMatch is returned if no file matches.
+func (p *PFS0) ExtractFirst(pred func(File) bool, destPath string) (File, error) {
+	ind, ok := p.FindFileFunc(pred)
+	if !ok {
+		return File{}, fmt.Errorf("%w in %s", ErrNoMatch, p.Basename)
+	}
+	return p.Files[ind], p.ExtractFile(ind, destPath)
+}
+
+// ExtractFileWith writes the file with the given index to destPath, applying
+// the per-file settings of opts (ModTime, UseSourceModTime and
+// VerifyAfterWrite)
+func (p *PFS0) ExtractFileWith(ind uint16, destPath string, opts ExtractOptions) error {
+	x, err := p.newExtraction(opts)
+	if err != nil {
+		return err
+	}
+	return p.extractTo(ind, destPath, x)
+}
+
+// ExtractFileMultiHash writes the file with the given index to destPath while
+// computing each of the requested digests (see newHash) in the same pass.
+// The returned map is keyed by algorithm name.
+func (p *PFS0) ExtractFileMultiHash(ind uint16, destPath string, algos []string) (map[string][]byte, error) {
+	hashes := make(map[string]hash.Hash, len(algos))
+	var writers []io.Writer
+	for _, algo := range algos {
+		h, err := newHash(algo)
+		if err != nil {
+			return nil, err
+		}
+		hashes[algo] = h
+		writers = append(writers, h)
+	}
+
+	if err := p.extractTo(ind, destPath, &extraction{extra: writers}); err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string][]byte, len(hashes))
+	for algo, h := range hashes {
+		digests[algo] = h.Sum(nil)
+	}
+	return digests, nil
+}
+
+// ExtractOptions controls how ExtractWith extracts files
+type ExtractOptions struct {
+	// Sequential extracts files in the order they are stored rather than by
+	// index, so the NSP is read strictly front to back
+	Sequential bool
+	// CheckSpace calls EnsureSpace before extracting anything
+	CheckSpace bool
+	// ContinueOnError extracts every file it can instead of stopping at the
+	// first failure. Failures are then reported together as an *ExtractError.
+	ContinueOnError bool
+	// Progress, when set, is called periodically with the bytes written so
+	// far across all files and the total content size, and once more when
+	// extraction finishes
+	Progress func(done, total int64)
+	// ModTime, when not zero, is set as the modification time of every file
+	// written, so extracted trees are reproducible. PFS0 stores no timestamps
+	// of its own. By default files keep the time they were written.
+	ModTime time.Time
+	// UseSourceModTime sets each file's modification time to that of the NSP.
+	// It takes precedence over ModTime.
+	UseSourceModTime bool
+	// Rename, when set, chooses the name each file is written under in place
+	// of its name in the PFS0. Files it returns an empty name for are skipped.
+	Rename func(f File) string
+	// VerifyAfterWrite re-reads every file once it is written and compares
+	// its SHA-256 with that of the data read from the NSP, failing with an
+	// error wrapping ErrVerifyFailed if they differ
+	VerifyAfterWrite bool
+	// SanitizeName maps each name, after Rename, to one that is safe to
+	// create on the destination filesystem. Nil uses DefaultSanitizeName.
+	// Names containing a path separator are refused whatever it returns.
+	SanitizeName func(name string) string
+}
+
+// ErrVerifyFailed is returned when a file read back after extraction does not
+// match what was written
+var ErrVerifyFailed = errors.New("Extracted file does not match source")
+
+// extraction holds the per-file settings resolved from ExtractOptions
+type extraction struct {
+	// extra receives a copy of everything written
+	extra   []io.Writer
+	modTime time.Time
+	verify  bool
+	// ctx, when set, aborts the write once it is done
+	ctx context.Context
+	// buf, when set, is the copy buffer shared by every file extracted with
+	// this extraction. It must not be used by two goroutines at once.
+	buf []byte
+}
+
+// newExtraction resolves opts into the settings used for each file
+func (p *PFS0) newExtraction(opts ExtractOptions) (*extraction, error) {
+	x := &extraction{modTime: opts.ModTime, verify: opts.VerifyAfterWrite}
+	if opts.UseSourceModTime {
+		modTime, err := p.sourceModTime()
+		if err != nil {
+			return nil, err
+		}
+		x.modTime = modTime
+	}
+	return x, nil
+}
+
+// sourceModTime returns the modification time of the underlying NSP file
+func (p *PFS0) sourceModTime() (time.Time, error) {
+	var fi os.FileInfo
+	var err error
+	switch f := p.src.(type) {
+	case nil:
+		fi, err = os.Stat(p.Filepath)
+	case *bytes.Reader:
+		if !p.mapped {
+			return time.Time{}, errors.New("PFS0 is not backed by a file and has no modification time")
+		}
+		fi, err = os.Stat(p.Filepath)
+	case *os.File:
+		fi, err = f.Stat()
+	default:
+		return time.Time{}, errors.New("PFS0 is not backed by a file and has no modification time")
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// ExtractError is returned by ExtractWith with ContinueOnError set when some
+// files could not be extracted. It unwraps to the errors.Join of each failure.
+type ExtractError struct {
+	// Extracted lists the files written successfully
+	Extracted []string
+	// Failed maps the name of each file that was not written to its error
+	Failed map[string]error
+
+	err error
+}
+
+func (e *ExtractError) Error() string {
+	return fmt.Sprintf("Failed to extract %d of %d files: %v", len(e.Failed), len(e.Failed)+len(e.Extracted), e.err)
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.err
+}
+
+// progressInterval is the minimum time between Progress calls
+const progressInterval = 100 * time.Millisecond
+
+// progressWriter counts bytes written through it and reports them to fn at
+// most once per progressInterval
+type progressWriter struct {
+	done  int64
+	total int64
+	last  time.Time
+	fn    func(done, total int64)
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.done += int64(len(b))
+	if now := time.Now(); now.Sub(w.last) >= progressInterval {
+		w.last = now
+		w.fn(w.done, w.total)
+	}
+	return len(b), nil
+}
+
+// ExtractAll writes every file in the PFS0 to destDir, named by its file name
+func (p *PFS0) ExtractAll(destDir string) error {
+	return p.ExtractWith(destDir, ExtractOptions{})
+}
+
+// ExtractAllSequential is like ExtractAll but extracts files in the order they
+// are stored, so the NSP is read strictly front to back
+func (p *PFS0) ExtractAllSequential(destDir string) error {
+	return p.ExtractWith(destDir, ExtractOptions{Sequential: true})
+}
+
+// ExtractAllProgress is like ExtractAll but periodically calls progress with
+// the bytes written so far across all files and the total to write
+func (p *PFS0) ExtractAllProgress(destDir string, progress func(done, total int64)) error {
+	return p.ExtractWith(destDir, ExtractOptions{Progress: progress})
+}
+
+// ExtractAllRenamed is like ExtractAll but writes each file under the name
+// returned by rename, skipping files it returns an empty name for
+func (p *PFS0) ExtractAllRenamed(destDir string, rename func(f File) string) error {
+	return p.ExtractWith(destDir, ExtractOptions{Rename: rename})
+}
+
+// contentStoreOther is the directory of a content store holding the files
+// that are not NCAs, such as tickets and certificates
+const contentStoreOther = "other"
+
+// ExtractToContentStore writes each NCA to rootDir/<xx>/<content ID>.nca,
+// where xx is the first two hex digits of its content ID, so identical NCAs
+// from different archives share one copy. Files that already exist are left
+// alone. Other files, such as tickets and certificates, go in the "other"
+// directory under rootDir under their DefaultSanitizeName names, also
+// skipped when present. Files are written
+// under a temporary name and renamed once complete, so an interrupted
+// extraction never leaves a file that would later be skipped.
+func (p *PFS0) ExtractToContentStore(rootDir string) error {
+	for i, f := range p.Files {
+		ext := fileExtension(f.Name)
+		dir := filepath.Join(rootDir, contentStoreOther)
+		name := DefaultSanitizeName(f.Name)
+		if ext == "nca" || ext == "cnmt.nca" {
+			contentID := strings.ToLower(strings.Split(f.Name, ".")[0])
+			if _, err := hex.DecodeString(contentID); err != nil || len(contentID) != 32 {
+				return fmt.Errorf("%s is not named after a content ID", f.Name)
+			}
+			dir = filepath.Join(rootDir, contentID[:2])
+			name = contentID + "." + ext
+		}
+		if err := checkFileName(name); err != nil {
+			return err
+		}
+		dest := filepath.Join(dir, name)
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Println(err)
+			return err
+		}
+		tmp := dest + ".part"
+		if err := p.extractTo(uint16(i), tmp, nil); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, dest); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractMetaOnly writes only the content meta (.cnmt.nca or .cnmt), ticket
+// and certificate files to destDir, skipping the NCAs holding the title's
+// data, for keeping a small metadata bundle per title
+func (p *PFS0) ExtractMetaOnly(destDir string) error {
+	return p.ExtractWith(destDir, ExtractOptions{Rename: func(f File) string {
+		switch fileExtension(f.Name) {
+		case "cnmt.nca", "cnmt", "tik", "cert":
+			return f.Name
+		}
+		return ""
+	}})
+}
+
+// ExtractAllWithManifest is like ExtractAll but hashes each file with algo
+// (see newHash) as it is written and then writes a manifest to manifestPath.
+// The manifest has one "<hex digest>  <name>" line per file, sorted by the
+// name it was written under, in the format read by tools such as
+// sha256sum -c. No manifest is written if any file fails to extract.
+func (p *PFS0) ExtractAllWithManifest(destDir string, manifestPath string, algo string) error {
+	if _, err := newHash(algo); err != nil {
+		return err
+	}
+	buf, release := p.copyBuffer()
+	defer release()
+	digests := make(map[string][]byte, len(p.Files))
+	for i, f := range p.Files {
+		h, _ := newHash(algo)
+		name := DefaultSanitizeName(f.Name)
+		if err := p.extractToDir(uint16(i), name, destDir, &extraction{extra: []io.Writer{h}, buf: buf}); err != nil {
+			return err
+		}
+		digests[name] = h.Sum(nil)
+	}
+
+	names := make([]string, 0, len(digests))
+	for name := range digests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out, err := os.Create(manifestPath)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(out, "%x  %s\n", digests[name], name); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// ExtractAllParallel is like ExtractAll but extracts up to workers files at
+// once, which pays off when the NSP is memory mapped with UseMmap or lives on
+// storage that serves parallel reads well. Every file is attempted and the
+// failures are returned joined together.
+func (p *PFS0) ExtractAllParallel(destDir string, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	indices := make(chan uint16)
+	errs := make([]error, len(p.Files))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker copies through a buffer of its own
+			buf, release := p.copyBuffer()
+			defer release()
+			x := &extraction{buf: buf}
+			for i := range indices {
+				if err := p.extractToDir(i, DefaultSanitizeName(p.Files[i].Name), destDir, x); err != nil {
+					errs[i] = fmt.Errorf("%s: %w", p.Files[i].Name, err)
+				}
+			}
+		}()
+	}
+	for i := range p.Files {
+		indices <- uint16(i)
+	}
+	close(indices)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// ExtractAllContext is like ExtractAll but stops once ctx is done, returning
+// ctx's error. The file being written at the time is removed, while files
+// already complete are kept. Cancellation is noticed between writes, so a
+// read that blocks is not interrupted.
+func (p *PFS0) ExtractAllContext(ctx context.Context, destDir string) error {
+	return p.extractWith(ctx, destDir, ExtractOptions{})
+}
+
+// ExtractAllTimeout is like ExtractAllContext but gives up after d
+func (p *PFS0) ExtractAllTimeout(destDir string, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return p.ExtractAllContext(ctx, destDir)
+}
+
+// ExtractWith writes every file in the PFS0 to destDir according to opts
+func (p *PFS0) ExtractWith(destDir string, opts ExtractOptions) error {
+	return p.extractWith(context.Background(), destDir, opts)
+}
+
+// extractWith implements ExtractWith, stopping once ctx is done
+func (p *PFS0) extractWith(ctx context.Context, destDir string, opts ExtractOptions) error {
+	if opts.CheckSpace {
+		if err := p.EnsureSpace(destDir); err != nil {
+			return err
+		}
+	}
+
+	indices := p.indicesByOffset()
+	if !opts.Sequential {
+		for i := range indices {
+			indices[i] = uint16(i)
+		}
+	}
+	names := p.IndexName()
+	if opts.Rename != nil {
+		for i, f := range p.Files {
+			names[i] = opts.Rename(f)
+		}
+	}
+	sanitize := opts.SanitizeName
+	if sanitize == nil {
+		sanitize = DefaultSanitizeName
+	}
+	for i, name := range names {
+		if name != "" {
+			names[i] = sanitize(name)
+		}
+	}
+	x, err := p.newExtraction(opts)
+	if err != nil {
+		return err
+	}
+	x.ctx = ctx
+	buf, release := p.copyBuffer()
+	defer release()
+	x.buf = buf
+	if opts.Progress != nil {
+		progress := &progressWriter{fn: opts.Progress, last: time.Now()}
+		for i, f := range p.Files {
+			if names[i] != "" {
+				progress.total += int64(f.Size)
+			}
+		}
+		x.extra = append(x.extra, progress)
+		defer func() { opts.Progress(progress.done, progress.total) }()
+	}
+
+	var extractErr *ExtractError
+	if opts.ContinueOnError {
+		extractErr = &ExtractError{Failed: make(map[string]error)}
+	}
+	var failures []error
+	for _, i := range indices {
+		name := names[i]
+		if name == "" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := p.extractToDir(i, name, destDir, x)
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return ctxErr
+		}
+		if extractErr == nil {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			extractErr.Failed[name] = err
+			failures = append(failures, fmt.Errorf("%s: %w", name, err))
+		} else {
+			extractErr.Extracted = append(extractErr.Extracted, name)
+		}
+	}
+	if len(failures) > 0 {
+		extractErr.err = errors.Join(failures...)
+		return extractErr
+	}
+	return nil
+}
+
+// ReadAllIntoMemory reads every file into a map keyed by file name. It fails
+// before reading anything if the files total more than maxTotal bytes.
+func (p *PFS0) ReadAllIntoMemory(maxTotal uint64) (map[string][]byte, error) {
+	var total uint64
+	for _, f := range p.Files {
+		if total+f.Size < total {
+			return nil, fmt.Errorf("Files total more than the 0x%X byte limit", maxTotal)
+		}
+		total += f.Size
+	}
+	if total > maxTotal {
+		return nil, fmt.Errorf("Files total 0x%X bytes, more than the 0x%X byte limit", total, maxTotal)
+	}
+
+	contents := make(map[string][]byte, len(p.Files))
+	for i, f := range p.Files {
+		if _, ok := contents[f.Name]; ok {
+			return nil, fmt.Errorf("Duplicate file name '%s'", f.Name)
+		}
+		content, err := p.readFile(uint16(i))
+		if err != nil {
+			return nil, err
+		}
+		contents[f.Name] = content
+	}
+	return contents, nil
+}
+
+// extractToDir writes the file with the given index into destDir as name
+func (p *PFS0) extractToDir(ind uint16, name, destDir string, x *extraction) error {
+	if err := checkFileName(name); err != nil {
+		return err
+	}
+	return p.extractTo(ind, filepath.Join(destDir, name), x)
+}
+
+// windowsReservedNames are device names Windows will not create a file as,
+// with or without an extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// DefaultSanitizeName makes name safe to create on Windows as well as other
+// systems. Control characters and the characters <>:"|?* become '_',
+// trailing dots and spaces are dropped and reserved device names such as CON
+// or com1.txt gain a leading '_'. Path separators are left for extraction to
+// refuse, as are "." and "..".
+func DefaultSanitizeName(name string) string {
+	if name == "." || name == ".." {
+		return name
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	stem := strings.TrimRight(strings.SplitN(name, ".", 2)[0], " ")
+	if windowsReservedNames[strings.ToUpper(stem)] {
+		name = "_" + name
+	}
+	return name
+}
+
+// checkFileName rejects names that would escape the extraction directory
+func checkFileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("Refusing to extract file with unsafe name '%s'", name)
+	}
+	return nil
+}
+
+// extractTo writes the file with the given index to destPath using the
+// settings in x, which may be nil
+func (p *PFS0) extractTo(ind uint16, destPath string, x *extraction) error {
+	if x == nil {
+		x = &extraction{}
+	}
+	out, err := os.Create(destPath)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	extra := x.extra
+	var source hash.Hash
+	if x.verify {
+		source = sha256.New()
+		extra = append(extra[:len(extra):len(extra)], source)
+	}
+	w := io.Writer(out)
+	if len(extra) > 0 {
+		w = io.MultiWriter(append([]io.Writer{out}, extra...)...)
+	}
+	if x.ctx != nil {
+		w = &contextWriter{x.ctx, w}
+	}
+	_, err = p.writeFileTo(ind, w, x.buf)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil && x.ctx != nil && x.ctx.Err() != nil {
+		os.Remove(destPath)
+		return err
+	}
+	if err == nil && source != nil {
+		err = verifyWritten(destPath, source.Sum(nil))
+	}
+	if err == nil && !x.modTime.IsZero() {
+		err = os.Chtimes(destPath, x.modTime, x.modTime)
+	}
+	return err
+}
+
+// contextWriter fails writes to w with ctx's error once ctx is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *contextWriter) Write(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(b)
+}
+
+// verifyWritten re-reads the file at path and checks its SHA-256 is want
+func verifyWritten(path string, want []byte) error {
+	got, err := hashPath(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: %s has SHA-256 %x, expected %x", ErrVerifyFailed, path, got, want)
+	}
+	return nil
+}
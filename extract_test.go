@@ -0,0 +1,144 @@
+package gopfs0
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+// countingReaderAt counts the reads made of r, each of which is a read from
+// the operating system when r is a file
+type countingReaderAt struct {
+	r     *os.File
+	reads int64
+}
+
+func (c *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	atomic.AddInt64(&c.reads, 1)
+	return c.r.ReadAt(b, off)
+}
+
+// writeFixtureFile writes a PFS0 holding files to a temporary file and
+// returns its path
+func writeFixtureFile(tb testing.TB, files map[string][]byte) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "fixture.nsp")
+	if err := os.WriteFile(path, pfs0test.BuildFixture(files), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func BenchmarkExtractAll(b *testing.B) {
+	files := make(map[string][]byte)
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("%02d.nca", i)] = make([]byte, 0x40000)
+	}
+	path := writeFixtureFile(b, files)
+
+	for _, bc := range []struct {
+		name    string
+		bufSize int
+	}{
+		{"buffered", 0},
+		{"unbuffered", -1},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			f, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer f.Close()
+			fi, err := f.Stat()
+			if err != nil {
+				b.Fatal(err)
+			}
+			src := &countingReaderAt{r: f}
+			p, err := NewPFS0FromReaderAt(src, fi.Size(), path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			p.SequentialBufferSize = bc.bufSize
+			dir := b.TempDir()
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(files) * 0x40000))
+			atomic.StoreInt64(&src.reads, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := p.ExtractAll(dir); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&src.reads))/float64(b.N), "reads/op")
+		})
+	}
+}
+
+func TestExtractAllWithManifest(t *testing.T) {
+	// con.nca is written as _con.nca, which sorts before b.nca
+	p := NewPFS0(writeFixtureFile(t, map[string][]byte{"b.nca": []byte("2"), "con.nca": []byte("1")}))
+	if err := p.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	manifest := filepath.Join(t.TempDir(), "SHA256SUMS")
+	if err := p.ExtractAllWithManifest(dir, manifest, "SHA256"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b  _con.nca\n" +
+		"d4735e3a265e16eee03f59718b9b5d03019c07d8b6c51f90da3a666eec13ab35  b.nca\n"
+	if string(got) != want {
+		t.Fatalf("manifest =\n%s\nwant\n%s", got, want)
+	}
+	for name, content := range map[string]string{"_con.nca": "1", "b.nca": "2"} {
+		if b, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(b) != content {
+			t.Errorf("extracted %s = %q, %v, want %q", name, b, err, content)
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), "MD4SUMS")
+	if err := p.ExtractAllWithManifest(t.TempDir(), missing, "md4"); err == nil {
+		t.Fatal("ExtractAllWithManifest accepted an unsupported algorithm")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Fatalf("manifest written for an unsupported algorithm: %v", err)
+	}
+}
+
+func TestExtractToContentStoreSanitizesOther(t *testing.T) {
+	content := []byte("nca")
+	digest := sha256.Sum256(content)
+	contentID := hex.EncodeToString(digest[:16])
+	p := NewPFS0(writeFixtureFile(t, map[string][]byte{
+		contentID + ".nca": content,
+		"a:b?.tik":         []byte("ticket"),
+		"con.cert":         []byte("cert"),
+	}))
+	if err := p.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	if err := p.ExtractToContentStore(root); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		filepath.Join(contentID[:2], contentID+".nca"): "nca",
+		filepath.Join(contentStoreOther, "a_b_.tik"):   "ticket",
+		filepath.Join(contentStoreOther, "_con.cert"):  "cert",
+	} {
+		if b, err := os.ReadFile(filepath.Join(root, path)); err != nil || string(b) != want {
+			t.Errorf("%s = %q, %v, want %q", path, b, err, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package gopfs0
+
+import (
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// humanSize formats n bytes using binary (1024-based) units, e.g. "4.2 GiB"
+func humanSize(n uint64) string {
+	if n < 1024 {
+		return strconv.FormatUint(n, 10) + " B"
+	}
+	v := float64(n)
+	unit := 0
+	for v >= 1024 && unit < len(sizeUnits)-1 {
+		v /= 1024
+		unit++
+	}
+	s := strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), ".0")
+	return s + " " + sizeUnits[unit]
+}
+
+// HumanSize returns the size of the file formatted with binary units
+// (KiB, MiB, GiB), e.g. "512 KiB"
+func (f File) HumanSize() string {
+	return humanSize(f.Size)
+}
+
+// HumanSize returns the size of the whole NSP formatted with binary units
+// (KiB, MiB, GiB), e.g. "4.2 GiB"
+func (p *PFS0) HumanSize() string {
+	return humanSize(p.Size)
+}
@@ -0,0 +1,37 @@
+package gopfs0
+
+import (
+	"io"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+func FuzzReadMetadata(f *testing.F) {
+	f.Add(pfs0test.BuildFixture(nil))
+	f.Add(pfs0test.BuildFixture(map[string][]byte{"a.nca": []byte("data")}))
+	f.Add(pfs0test.BuildFixture(map[string][]byte{
+		"0123456789abcdef0123456789abcdef.nca":      make([]byte, 0x40),
+		"0123456789abcdef0123456789abcdef.cnmt.nca": make([]byte, 0x20),
+		"0100000000010000000000000000000a.tik":      make([]byte, 0x2C0),
+		"0100000000010000000000000000000a.cert":     make([]byte, 0x10),
+	}))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ValidateHeader(b)
+
+		p := &PFS0{}
+		if err := p.ReadMetadataFromBytes(b); err != nil {
+			if p.Files != nil {
+				t.Fatalf("Files holds %d entries after error %v", len(p.Files), err)
+			}
+			return
+		}
+		p.Validate()
+		p.LayoutReport()
+		p.ReadTik()
+		p.RequiredKeyGeneration()
+		for i := range p.Files {
+			p.WriteFileTo(uint16(i), io.Discard)
+		}
+	})
+}
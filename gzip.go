@@ -0,0 +1,118 @@
+package gopfs0
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// GzipMemoryLimit is the largest decompressed size NewPFS0FromGzip keeps in
+// memory. Larger archives, or ones of unknown size, are decompressed into a
+// temporary file.
+const GzipMemoryLimit = 64 << 20 // 64 MiB
+
+// NewPFS0FromGzip decompresses the gzip stream r once and reads the metadata
+// of the NSP inside it. size is the decompressed size if known, or a negative
+// value otherwise, and only decides where the data is kept. The decompressed
+// data is held in memory when it is at most GzipMemoryLimit bytes and in a
+// temporary file otherwise, which is removed on Close. The stream is read
+// with the Decompressor registered for "gzip", so RegisterDecompressor can
+// swap in a faster implementation.
+func NewPFS0FromGzip(r io.Reader, size int64, name string) (*PFS0, error) {
+	return newPFS0FromGzip(r, size, name, GzipMemoryLimit)
+}
+
+// NewPFS0FromGzipFile reads the gzip-compressed NSP at path like
+// NewPFS0FromGzip. Archives that decompress to at most memThreshold bytes are
+// kept in memory and larger ones in a temporary file removed on Close. A zero
+// memThreshold uses GzipMemoryLimit and a negative one always uses a
+// temporary file. The decompressed size is taken from the gzip trailer.
+func NewPFS0FromGzipFile(path string, memThreshold int64) (*PFS0, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer f.Close()
+
+	if memThreshold == 0 {
+		memThreshold = GzipMemoryLimit
+	}
+	// The trailer records the size modulo 2^32, so it is only a hint and
+	// newPFS0FromGzip still switches to a file if the data runs longer
+	size := int64(-1)
+	trailer := make([]byte, 4)
+	if fi, err := f.Stat(); err == nil && fi.Size() >= 4 {
+		if _, err := f.ReadAt(trailer, fi.Size()-4); err == nil {
+			size = int64(binary.LittleEndian.Uint32(trailer))
+		}
+	}
+	return newPFS0FromGzip(f, size, path, memThreshold)
+}
+
+// newPFS0FromGzip implements NewPFS0FromGzip, keeping archives of at most
+// memLimit bytes in memory
+func newPFS0FromGzip(r io.Reader, size int64, name string, memLimit int64) (*PFS0, error) {
+	zr, err := NewDecompressReader("gzip", r)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read gzip header of %s: %w", name, err)
+	}
+	if c, ok := zr.(io.Closer); ok {
+		defer c.Close()
+	}
+	return newPFS0FromStream(zr, size, name, memLimit)
+}
+
+// newPFS0FromStream reads the NSP in r, which holds size bytes or a negative
+// size if unknown, into memory when it is at most memLimit bytes and into a
+// temporary file removed on Close otherwise
+func newPFS0FromStream(r io.Reader, size int64, name string, memLimit int64) (*PFS0, error) {
+	var head []byte
+	var err error
+	// Try memory first unless the archive is known to be too large, falling
+	// back to a temporary file if the stream turns out longer than size said
+	if size >= 0 && size <= memLimit {
+		head, err = io.ReadAll(io.LimitReader(r, memLimit+1))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read %s: %w", name, err)
+		}
+		if int64(len(head)) <= memLimit {
+			return NewPFS0FromReaderAt(bytes.NewReader(head), int64(len(head)), name)
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "gopfs0-*.nsp")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	t := &tempFile{tmp}
+	n, err := io.Copy(tmp, io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("Unable to read %s: %w", name, err)
+	}
+	p, err := NewPFS0FromReaderAt(tmp, n, name)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	p.closer = t
+	return p, nil
+}
+
+// tempFile is a temporary file that is removed once closed
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	if removeErr := os.Remove(t.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package gopfs0
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+// gzipped returns b compressed with gzip
+func gzipped(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewPFS0FromGzip(t *testing.T) {
+	nsp := pfs0test.BuildFixture(map[string][]byte{"a.nca": bytes.Repeat([]byte("a"), 0x1000)})
+	z := gzipped(t, nsp)
+
+	for _, tc := range []struct {
+		name     string
+		size     int64
+		memLimit int64
+	}{
+		{"memory", int64(len(nsp)), GzipMemoryLimit},
+		{"unknown size", -1, GzipMemoryLimit},
+		{"over limit", int64(len(nsp)), 0x100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := newPFS0FromGzip(bytes.NewReader(z), tc.size, "a.nsp.gz", tc.memLimit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			var out bytes.Buffer
+			if _, err := p.WriteFileTo(0, &out); err != nil || out.Len() != 0x1000 {
+				t.Fatalf("WriteFileTo = %d bytes, %v", out.Len(), err)
+			}
+		})
+	}
+}
+
+func TestNewPFS0FromGzipUsesRegisteredDecompressor(t *testing.T) {
+	decompressorsMu.RLock()
+	builtin := decompressors["gzip"]
+	decompressorsMu.RUnlock()
+	defer RegisterDecompressor("gzip", builtin)
+
+	calls := 0
+	RegisterDecompressor("gzip", DecompressorFunc(func(r io.Reader) (io.Reader, error) {
+		calls++
+		return builtin.NewReader(r)
+	}))
+	nsp := pfs0test.BuildFixture(map[string][]byte{"a.nca": []byte("a")})
+	p, err := NewPFS0FromGzip(bytes.NewReader(gzipped(t, nsp)), -1, "a.nsp.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if calls != 1 {
+		t.Fatalf("registered gzip decompressor called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,269 @@
+package gopfs0
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+)
+
+// newHash returns a hash for the named algorithm: crc32, md5, sha1 or sha256
+func newHash(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("Unsupported hash algorithm '%s'", algo)
+}
+
+// HashFile returns the SHA-256 digest of the file with the given index. If
+// EnableHashCache has been called, a digest already computed for the same
+// region of the NSP is reused.
+func (p *PFS0) HashFile(ind uint16) ([32]byte, error) {
+	var digest [32]byte
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return digest, err
+	}
+	key := [2]uint64{offset, size}
+
+	p.hashCacheMu.Lock()
+	cached, ok := p.hashCache[key]
+	p.hashCacheMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	h := sha256.New()
+	if _, err := p.WriteFileTo(ind, h); err != nil {
+		return digest, err
+	}
+	copy(digest[:], h.Sum(nil))
+
+	p.hashCacheMu.Lock()
+	if p.hashCache != nil {
+		p.hashCache[key] = digest
+	}
+	p.hashCacheMu.Unlock()
+	return digest, nil
+}
+
+// EnableHashCache makes HashFile remember digests by file offset and size, so
+// hashing the same file again skips reading it. This is only valid while the
+// NSP does not change; call ResetHashCache if it might have.
+func (p *PFS0) EnableHashCache() {
+	p.hashCacheMu.Lock()
+	defer p.hashCacheMu.Unlock()
+	if p.hashCache == nil {
+		p.hashCache = make(map[[2]uint64][32]byte)
+	}
+}
+
+// ResetHashCache forgets all digests remembered by HashFile. The cache stays
+// enabled if it was.
+func (p *PFS0) ResetHashCache() {
+	p.hashCacheMu.Lock()
+	defer p.hashCacheMu.Unlock()
+	if p.hashCache != nil {
+		p.hashCache = make(map[[2]uint64][32]byte)
+	}
+}
+
+// FilesIdentical reports whether the files with indices a and b hold the same
+// bytes
+func (p *PFS0) FilesIdentical(a, b uint16) (bool, error) {
+	offsetA, sizeA, err := p.fileRegion(a)
+	if err != nil {
+		return false, err
+	}
+	offsetB, sizeB, err := p.fileRegion(b)
+	if err != nil {
+		return false, err
+	}
+	if sizeA != sizeB {
+		return false, nil
+	}
+	if offsetA == offsetB {
+		return true, nil
+	}
+
+	hashA, err := p.HashFile(a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := p.HashFile(b)
+	if err != nil {
+		return false, err
+	}
+	return hashA == hashB, nil
+}
+
+// DuplicateContentGroups returns the indices of files that share identical
+// content, one group per distinct content, ordered by their lowest index. Only
+// files with the same size as another file are hashed.
+func (p *PFS0) DuplicateContentGroups() ([][]uint16, error) {
+	bySize := make(map[uint64][]uint16)
+	for i, f := range p.Files {
+		bySize[f.Size] = append(bySize[f.Size], uint16(i))
+	}
+
+	var groups [][]uint16
+	for i, f := range p.Files {
+		candidates := bySize[f.Size]
+		if len(candidates) < 2 || candidates[0] != uint16(i) {
+			continue
+		}
+		byHash := make(map[[32]byte][]uint16)
+		var order [][32]byte
+		for _, ind := range candidates {
+			digest, err := p.HashFile(ind)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := byHash[digest]; !ok {
+				order = append(order, digest)
+			}
+			byHash[digest] = append(byHash[digest], ind)
+		}
+		for _, digest := range order {
+			if len(byHash[digest]) > 1 {
+				groups = append(groups, byHash[digest])
+			}
+		}
+	}
+	sort.Slice(groups, func(a, b int) bool { return groups[a][0] < groups[b][0] })
+	return groups, nil
+}
+
+// MetadataFingerprint returns a SHA-256 over the name, size and offset of
+// every file, taken in name order so the order of the entry table does not
+// matter. Archives with the same structure share a fingerprint, which makes
+// it a cheap cache key or first pass before hashing content.
+func (p *PFS0) MetadataFingerprint() [32]byte {
+	var sum [32]byte
+	p.hashLayout(sha256.New()).Sum(sum[:0])
+	return sum
+}
+
+// LayoutChecksum returns a CRC32 over the same sorted name, size and offset
+// records as MetadataFingerprint. It is a compact golden value for checking
+// that parsing of a fixture has not changed.
+func (p *PFS0) LayoutChecksum() uint32 {
+	return p.hashLayout(crc32.NewIEEE()).(hash.Hash32).Sum32()
+}
+
+// ContentHash returns a SHA-256 over the name, size and SHA-256 of every
+// file, sorted by name, size and then digest. It depends only on what the
+// archive holds, so archives with the same files in a different order or
+// with different padding share a ContentHash, making it a key for
+// deduplicating archives. Each file is hashed with HashFile.
+func (p *PFS0) ContentHash() ([32]byte, error) {
+	type record struct {
+		name   string
+		size   uint64
+		digest [32]byte
+	}
+	records := make([]record, len(p.Files))
+	for i, f := range p.Files {
+		digest, err := p.HashFile(uint16(i))
+		if err != nil {
+			return [32]byte{}, fmt.Errorf("Unable to hash %s: %w", f.Name, err)
+		}
+		records[i] = record{f.Name, f.Size, digest}
+	}
+	sort.Slice(records, func(a, b int) bool {
+		if records[a].name != records[b].name {
+			return records[a].name < records[b].name
+		}
+		if records[a].size != records[b].size {
+			return records[a].size < records[b].size
+		}
+		return bytes.Compare(records[a].digest[:], records[b].digest[:]) < 0
+	})
+
+	h := sha256.New()
+	var buf []byte
+	for _, r := range records {
+		buf = append(buf[:0], r.name...)
+		buf = append(buf, 0)
+		buf = binary.LittleEndian.AppendUint64(buf, r.size)
+		buf = append(buf, r.digest[:]...)
+		h.Write(buf)
+	}
+	var sum [32]byte
+	h.Sum(sum[:0])
+	return sum, nil
+}
+
+// hashLayout writes the name, size and offset of every file to h, sorted by
+// name and then by offset and size so the result is independent of entry
+// order, and returns h
+func (p *PFS0) hashLayout(h hash.Hash) hash.Hash {
+	files := make([]File, len(p.Files))
+	copy(files, p.Files)
+	sort.Slice(files, func(a, b int) bool {
+		if files[a].Name != files[b].Name {
+			return files[a].Name < files[b].Name
+		}
+		if files[a].StartOffset != files[b].StartOffset {
+			return files[a].StartOffset < files[b].StartOffset
+		}
+		return files[a].Size < files[b].Size
+	})
+
+	var buf []byte
+	for _, f := range files {
+		buf = append(buf[:0], f.Name...)
+		buf = append(buf, 0)
+		buf = binary.LittleEndian.AppendUint64(buf, f.Size)
+		buf = binary.LittleEndian.AppendUint64(buf, f.StartOffset)
+		h.Write(buf)
+	}
+	return h
+}
+
+// PieceHashes returns the SHA-1 of each consecutive pieceLength bytes of the
+// whole NSP, header included, as BitTorrent hashes the pieces of a single
+// file torrent. The last piece covers whatever remains and may be shorter.
+// The NSP is read once, front to back.
+func (p *PFS0) PieceHashes(pieceLength int) ([][20]byte, error) {
+	if pieceLength <= 0 {
+		return nil, fmt.Errorf("Invalid piece length %d", pieceLength)
+	}
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+
+	r, release := p.sequentialReader(fileHandle, 0, p.Size)
+	defer release()
+	pieces := make([][20]byte, 0, (p.Size+uint64(pieceLength)-1)/uint64(pieceLength))
+	piece := make([]byte, pieceLength)
+	for read := uint64(0); read < p.Size; {
+		n, err := io.ReadFull(r, piece)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if read+uint64(n) < p.Size {
+				return nil, fmt.Errorf("%w: NSP ended after 0x%X of 0x%X bytes", ErrTruncated, read+uint64(n), p.Size)
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, sha1.Sum(piece[:n]))
+		read += uint64(n)
+	}
+	return pieces, nil
+}
@@ -0,0 +1,169 @@
+package gopfs0
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/nosmokingbandit/gopfs0/internal/pfs0fmt"
+)
+
+// Sizes of the parts of a PFS0 header
+const (
+	// HeaderBaseSize is the size of the fixed header that starts every PFS0
+	// and HFS0, before the entry table
+	HeaderBaseSize = pfs0fmt.HeaderBaseSize
+	// EntrySize is the size of one PFS0 entry table record
+	EntrySize = pfs0fmt.EntrySize
+	// HFS0EntrySize is the size of one HFS0 entry table record
+	HFS0EntrySize = 0x40
+	// Pfs0HeaderSize is the boundary the header and string table of written
+	// PFS0s are padded to, matching common packing tools
+	Pfs0HeaderSize = pfs0fmt.HeaderAlign
+)
+
+// pfs0Header is the fixed 0x10 byte header shared by PFS0 and HFS0
+type pfs0Header struct {
+	Magic           [4]byte
+	FileCount       uint32
+	StringTableSize uint32
+	Reserved        uint32
+}
+
+// pfs0Entry is one 0x18 byte PFS0 entry table record
+type pfs0Entry struct {
+	Offset     uint64
+	Size       uint64
+	NameOffset uint32
+	Reserved   uint32
+}
+
+// hfs0Entry is one 0x40 byte HFS0 entry table record
+type hfs0Entry struct {
+	Offset           uint64
+	Size             uint64
+	NameOffset       uint32
+	HashedRegionSize uint32
+	Reserved         uint64
+	Hash             [0x20]byte
+}
+
+// decodeHeader decodes the 0x10 byte header in b
+func decodeHeader(b []byte) (pfs0Header, error) {
+	var hdr pfs0Header
+	err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &hdr)
+	return hdr, err
+}
+
+// decodeEntries decodes count entry table records from b as the PFS0 layout,
+// or the HFS0 layout when hfs0 is set, converting both to pfs0Entry
+func decodeEntries(b []byte, count uint32, hfs0 bool) ([]pfs0Entry, error) {
+	entries := make([]pfs0Entry, count)
+	if !hfs0 {
+		err := binary.Read(bytes.NewReader(b), binary.LittleEndian, entries)
+		return entries, err
+	}
+
+	hfs0Entries := make([]hfs0Entry, count)
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, hfs0Entries); err != nil {
+		return nil, err
+	}
+	for i, e := range hfs0Entries {
+		entries[i] = pfs0Entry{Offset: e.Offset, Size: e.Size, NameOffset: e.NameOffset}
+	}
+	return entries, nil
+}
+
+// PFS0Header is the fixed header of a PFS0 or HFS0 along with the values
+// derived from it
+type PFS0Header struct {
+	Magic           [4]byte
+	FileCount       uint32
+	StringTableSize uint32
+	Reserved        uint32
+
+	// HeaderLen is the size of the fixed header plus the entry table
+	HeaderLen uint32
+	// ContentBase is the absolute offset file data is relative to
+	ContentBase uint64
+}
+
+// Header reads and decodes the fixed header of the PFS0. ReadMetadata must
+// have been called first so the header can be located.
+func (p *PFS0) Header() (PFS0Header, error) {
+	b, err := p.readAt(p.BaseOffset, HeaderBaseSize)
+	if err != nil {
+		return PFS0Header{}, err
+	}
+	hdr, err := decodeHeader(b)
+	if err != nil {
+		return PFS0Header{}, err
+	}
+	headerLen := p.format().headerLen(hdr.FileCount)
+	return PFS0Header{
+		Magic:           hdr.Magic,
+		FileCount:       hdr.FileCount,
+		StringTableSize: hdr.StringTableSize,
+		Reserved:        hdr.Reserved,
+		HeaderLen:       uint32(headerLen),
+		ContentBase:     p.BaseOffset + headerLen + uint64(hdr.StringTableSize),
+	}, nil
+}
+
+// ValidateHeader parses and checks the PFS0 or HFS0 header at the start of
+// buf without any IO, returning the file count, string table size and the
+// length of the header plus entry table. buf needs to hold at least the 0x10
+// byte fixed header. When it also holds the entry and string tables, every
+// entry's name offset is checked as well, so a client can vet an archive from
+// its first few KiB.
+func ValidateHeader(buf []byte) (fileCount uint16, stringTableSize uint32, headerLen uint32, err error) {
+	hdr, format, length, err := parseHeader(buf)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if uint64(len(buf)) >= length {
+		entries, err := decodeEntries(buf[HeaderBaseSize:length], hdr.FileCount, format == FormatHFS0)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for i, e := range entries {
+			if err := checkNameOffset(i, e.NameOffset, hdr.StringTableSize); err != nil {
+				return 0, 0, 0, err
+			}
+		}
+	}
+	return uint16(hdr.FileCount), hdr.StringTableSize, uint32(length), nil
+}
+
+// parseHeader decodes and checks the fixed header at the start of buf,
+// returning it along with its format and the length of the header plus entry
+// table. It implements the checks shared by ValidateHeader and ReadMetadata.
+func parseHeader(buf []byte) (pfs0Header, Format, uint64, error) {
+	if len(buf) < HeaderBaseSize {
+		return pfs0Header{}, 0, 0, fmt.Errorf("%w: header needs 0x%X bytes, got 0x%X", ErrTruncated, HeaderBaseSize, len(buf))
+	}
+	hdr, err := decodeHeader(buf[:HeaderBaseSize])
+	if err != nil {
+		return pfs0Header{}, 0, 0, err
+	}
+	format, ok := lookupFormat(string(hdr.Magic[:]))
+	if !ok {
+		return pfs0Header{}, 0, 0, errors.New("Invalid NSP header. Expected 'PFS0', got '" + string(hdr.Magic[:]) + "'")
+	}
+	// Files are addressed by uint16 index, so any beyond that could not be read
+	if hdr.FileCount > math.MaxUint16 {
+		return pfs0Header{}, 0, 0, fmt.Errorf("Header declares %d files, more than the %d that can be indexed", hdr.FileCount, math.MaxUint16)
+	}
+	return hdr, format, format.headerLen(hdr.FileCount), nil
+}
+
+// checkNameOffset rejects entry i if its name starts outside the string table
+func checkNameOffset(i int, nameOffset, stringTableSize uint32) error {
+	if nameOffset >= stringTableSize {
+		return fmt.Errorf("%w: file %d has name offset 0x%X but the string table is only 0x%X bytes",
+			ErrInvalidNameOffset, i, nameOffset, stringTableSize)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package gopfs0
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+// legacyEntries decodes an entry table by slicing fixed offsets, as parsing
+// did before the typed structs, for checking decodeEntries against
+func legacyEntries(b []byte, count uint32, entrySize uint64) []pfs0Entry {
+	entries := make([]pfs0Entry, count)
+	for i := range entries {
+		e := b[entrySize*uint64(i) : entrySize*uint64(i+1)]
+		entries[i] = pfs0Entry{
+			Offset:     binary.LittleEndian.Uint64(e[0:8]),
+			Size:       binary.LittleEndian.Uint64(e[8:16]),
+			NameOffset: binary.LittleEndian.Uint32(e[16:20]),
+		}
+		// Only PFS0 records keep their reserved field
+		if entrySize == EntrySize {
+			entries[i].Reserved = binary.LittleEndian.Uint32(e[20:24])
+		}
+	}
+	return entries
+}
+
+// entryTable returns count entry records of entrySize bytes filled with
+// distinct values, including ones that use the top bits of each field
+func entryTable(count int, entrySize int) []byte {
+	b := make([]byte, count*entrySize)
+	for i := 0; i < count; i++ {
+		e := b[i*entrySize:]
+		binary.LittleEndian.PutUint64(e[0:], uint64(i)*0x1000|uint64(i)<<56)
+		binary.LittleEndian.PutUint64(e[8:], 0xFFFFFFFFFFFFFFFF-uint64(i))
+		binary.LittleEndian.PutUint32(e[16:], uint32(i)*0x11|0x80000000)
+		binary.LittleEndian.PutUint32(e[20:], 0xA5A5A5A5^uint32(i))
+		for j := 24; j < entrySize; j++ {
+			e[j] = byte(i + j)
+		}
+	}
+	return b
+}
+
+func TestDecodeEntriesMatchesLegacy(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		count int
+		hfs0  bool
+	}{
+		{"PFS0 empty", 0, false},
+		{"PFS0 one", 1, false},
+		{"PFS0 many", 37, false},
+		{"HFS0 empty", 0, true},
+		{"HFS0 one", 1, true},
+		{"HFS0 many", 37, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			entrySize := EntrySize
+			if tc.hfs0 {
+				entrySize = HFS0EntrySize
+			}
+			b := entryTable(tc.count, entrySize)
+			got, err := decodeEntries(b, uint32(tc.count), tc.hfs0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := legacyEntries(b, uint32(tc.count), uint64(entrySize))
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("decodeEntries = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeHeaderMatchesLegacy(t *testing.T) {
+	for _, magic := range []string{"PFS0", "HFS0"} {
+		b := []byte(magic + "\x03\x00\x00\x00\x28\x00\x00\x00\xEF\xBE\xAD\xDE")
+		hdr, err := decodeHeader(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(hdr.Magic[:]) != magic ||
+			hdr.FileCount != binary.LittleEndian.Uint32(b[0x4:0x8]) ||
+			hdr.StringTableSize != binary.LittleEndian.Uint32(b[0x8:0xC]) ||
+			hdr.Reserved != binary.LittleEndian.Uint32(b[0xC:0x10]) {
+			t.Fatalf("decodeHeader(%q) = %+v", b, hdr)
+		}
+	}
+}
+
+// buildHFS0 returns an HFS0 holding one file per name, each holding its own
+// name as data
+func buildHFS0(names []string) []byte {
+	var table []byte
+	var data []byte
+	entries := make([]byte, HFS0EntrySize*len(names))
+	for i, name := range names {
+		e := entries[HFS0EntrySize*i:]
+		binary.LittleEndian.PutUint64(e[0:], uint64(len(data)))
+		binary.LittleEndian.PutUint64(e[8:], uint64(len(name)))
+		binary.LittleEndian.PutUint32(e[16:], uint32(len(table)))
+		binary.LittleEndian.PutUint32(e[20:], 0x200)
+		table = append(table, name...)
+		table = append(table, 0)
+		data = append(data, name...)
+	}
+	b := []byte("HFS0")
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(names)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(table)))
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, entries...)
+	b = append(b, table...)
+	return append(b, data...)
+}
+
+func TestParseHFS0(t *testing.T) {
+	names := []string{"update", "normal", "secure"}
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(buildHFS0(names)); err != nil {
+		t.Fatal(err)
+	}
+	if p.Magic != "HFS0" || len(p.Files) != len(names) {
+		t.Fatalf("parsed magic %q with %d files", p.Magic, len(p.Files))
+	}
+	var offset uint64
+	for i, f := range p.Files {
+		want := File{StartOffset: offset, Size: uint64(len(names[i])), Name: names[i]}
+		if f != want {
+			t.Errorf("file %d = %+v, want %+v", i, f, want)
+		}
+		offset += f.Size
+	}
+	content, err := p.readFile(2)
+	if err != nil || string(content) != "secure" {
+		t.Fatalf("readFile(2) = %q, %v", content, err)
+	}
+}
+
+func TestValidateHeader(t *testing.T) {
+	valid := pfs0test.BuildFixture(map[string][]byte{"a.nca": []byte("abc"), "b.tik": []byte("de")})
+	stringTableSize := binary.LittleEndian.Uint32(valid[0x8:])
+
+	fileCount, tableSize, headerLen, err := ValidateHeader(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileCount != 2 || tableSize != stringTableSize || headerLen != HeaderBaseSize+2*EntrySize {
+		t.Fatalf("ValidateHeader = %d, 0x%X, 0x%X", fileCount, tableSize, headerLen)
+	}
+	// The fixed header alone is enough to peek at the counts
+	if fileCount, _, _, err := ValidateHeader(valid[:HeaderBaseSize]); err != nil || fileCount != 2 {
+		t.Fatalf("ValidateHeader of the fixed header = %d, %v", fileCount, err)
+	}
+
+	badMagic := bytes.Clone(valid)
+	copy(badMagic, "PFS1")
+	tooMany := bytes.Clone(valid[:HeaderBaseSize])
+	binary.LittleEndian.PutUint32(tooMany[0x4:], math.MaxUint16+1)
+	badName := bytes.Clone(valid)
+	setNameOffset(badName, 1, stringTableSize)
+
+	for _, tc := range []struct {
+		name   string
+		buf    []byte
+		target error
+		want   string
+	}{
+		{"short buffer", valid[:HeaderBaseSize-1], ErrTruncated, "header needs 0x10 bytes, got 0xF"},
+		{"bad magic", badMagic, nil, "got 'PFS1'"},
+		{"too many files", tooMany, nil, "declares 65536 files"},
+		{"bad name offset", badName, ErrInvalidNameOffset, "file 1 has name offset"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, _, err := ValidateHeader(tc.buf)
+			if err == nil {
+				t.Fatal("ValidateHeader accepted the header")
+			}
+			if tc.target != nil && !errors.Is(err, tc.target) {
+				t.Fatalf("ValidateHeader error = %v, want %v", err, tc.target)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("ValidateHeader error = %q, want it to contain %q", err, tc.want)
+			}
+			// ReadMetadata shares these checks, so fails the same way once
+			// there are enough bytes to read a header from
+			if len(tc.buf) < HeaderBaseSize {
+				return
+			}
+			if parseErr := (&PFS0{}).ReadMetadataFromBytes(tc.buf); parseErr == nil || parseErr.Error() != err.Error() {
+				t.Fatalf("ReadMetadataFromBytes error = %v, want %q", parseErr, err)
+			}
+		})
+	}
+}
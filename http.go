@@ -0,0 +1,104 @@
+package gopfs0
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// NewPFS0FromURL reads the metadata of the NSP at rawURL over HTTP. Its size
+// is taken from a HEAD request and everything else is fetched with range
+// requests as it is needed, so the server must support them. Each function
+// in opts is applied to every request, both the HEAD and each range request,
+// to attach credentials such as an Authorization header or cookies.
+func NewPFS0FromURL(rawURL string, opts ...func(*http.Request)) (*PFS0, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	r := &httpReaderAt{client: http.DefaultClient, url: rawURL, opts: opts}
+
+	req, err := r.newRequest(http.MethodHead)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to open %s: %s", rawURL, resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("Unable to open %s: server did not report its size", rawURL)
+	}
+	r.size = resp.ContentLength
+
+	p, err := NewPFS0FromReaderAt(r, r.size, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	p.Basename = basename(path.Base(u.Path))
+	return p, nil
+}
+
+// httpReaderAt reads a remote file with HTTP range requests
+type httpReaderAt struct {
+	client *http.Client
+	url    string
+	size   int64
+	opts   []func(*http.Request)
+}
+
+// newRequest returns a request for the file with every option applied
+func (h *httpReaderAt) newRequest(method string) (*http.Request, error) {
+	req, err := http.NewRequest(method, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range h.opts {
+		opt(req)
+	}
+	return req, nil
+}
+
+func (h *httpReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Invalid offset %d", off)
+	}
+	if off >= h.size {
+		return 0, io.EOF
+	}
+	want := b
+	if int64(len(want)) > h.size-off {
+		want = want[:h.size-off]
+	}
+	if len(want) == 0 {
+		return 0, nil
+	}
+
+	req, err := h.newRequest(http.MethodGet)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(want))-1))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("Range request for %s failed: %s", h.url, resp.Status)
+	}
+	n, err := io.ReadFull(resp.Body, want)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err == nil && len(want) < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,55 @@
+// Package pfs0fmt encodes PFS0 headers. It is shared by gopfs0 and pfs0test,
+// which cannot import gopfs0 as gopfs0's own tests use it.
+package pfs0fmt
+
+import "encoding/binary"
+
+const (
+	// Magic starts every PFS0
+	Magic = "PFS0"
+	// HeaderBaseSize is the size of the fixed header, before the entry table
+	HeaderBaseSize = 0x10
+	// EntrySize is the size of one entry table record
+	EntrySize = 0x18
+	// HeaderAlign is the boundary the header and string table are padded to
+	HeaderAlign = 0x20
+)
+
+// Entry is one file described by a header
+type Entry struct {
+	Name string
+	// Offset is relative to the end of the padded string table
+	Offset   uint64
+	Size     uint64
+	Reserved uint32
+}
+
+// BuildHeader returns the fixed header, entry table and string table
+// describing entries. The string table is padded so the whole header is a
+// multiple of HeaderAlign bytes.
+func BuildHeader(entries []Entry) []byte {
+	var names []byte
+	nameOffsets := make([]uint32, len(entries))
+	for i, e := range entries {
+		nameOffsets[i] = uint32(len(names))
+		names = append(names, e.Name...)
+		names = append(names, 0)
+	}
+	headerLen := HeaderBaseSize + EntrySize*len(entries)
+	for (headerLen+len(names))%HeaderAlign != 0 {
+		names = append(names, 0)
+	}
+
+	header := make([]byte, 0, headerLen+len(names))
+	header = append(header, Magic...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(entries)))
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(names)))
+	header = binary.LittleEndian.AppendUint32(header, 0)
+	for i, e := range entries {
+		header = binary.LittleEndian.AppendUint64(header, e.Offset)
+		header = binary.LittleEndian.AppendUint64(header, e.Size)
+		header = binary.LittleEndian.AppendUint32(header, nameOffsets[i])
+		header = binary.LittleEndian.AppendUint32(header, e.Reserved)
+	}
+	return append(header, names...)
+}
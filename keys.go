@@ -0,0 +1,89 @@
+package gopfs0
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// ErrKeysRequired is returned when content is encrypted and the keyset does
+// not hold the key needed to decrypt it
+var ErrKeysRequired = errors.New("Required key is missing from keyset")
+
+// Keyset holds console keys by the names used in prod.keys, e.g. header_key
+// or title_kek_00
+type Keyset struct {
+	keys map[string][]byte
+}
+
+// LoadKeyset reads a keyset from a prod.keys style file at path
+func LoadKeyset(path string) (*Keyset, error) {
+	fileHandle, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer fileHandle.Close()
+	return ParseKeyset(fileHandle)
+}
+
+// ParseKeyset reads "name = hex" lines, as found in prod.keys, from r. Blank
+// lines and lines starting with ';' or '#' are ignored.
+func ParseKeyset(r io.Reader) (*Keyset, error) {
+	k := &Keyset{keys: make(map[string][]byte)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == ';' || text[0] == '#' {
+			continue
+		}
+		name, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("Invalid keyset line %d. Expected 'name = value'", line)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key on keyset line %d: %v", line, err)
+		}
+		k.keys[strings.ToLower(strings.TrimSpace(name))] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Key returns the named key, if present. Names are case insensitive.
+func (k *Keyset) Key(name string) ([]byte, bool) {
+	if k == nil {
+		return nil, false
+	}
+	key, ok := k.keys[strings.ToLower(name)]
+	return key, ok
+}
+
+// SetKey adds or replaces the named key
+func (k *Keyset) SetKey(name string, key []byte) {
+	if k.keys == nil {
+		k.keys = make(map[string][]byte)
+	}
+	k.keys[strings.ToLower(name)] = key
+}
+
+// requireKey returns the named key, failing with ErrKeysRequired unless it is
+// present and size bytes long
+func (k *Keyset) requireKey(name string, size int) ([]byte, error) {
+	key, ok := k.Key(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeysRequired, name)
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("Key %s must be 0x%X bytes, got 0x%X", name, size, len(key))
+	}
+	return key, nil
+}
@@ -0,0 +1,183 @@
+package gopfs0
+
+import (
+	"io"
+	"sort"
+)
+
+// Layout describes how the files of a PFS0 are arranged on disk. All offsets
+// are absolute.
+type Layout struct {
+	ContentBase uint64
+	// Files are sorted by Start
+	Files []FileExtent
+	// Gaps are regions between the content base and the end of the last file
+	// that hold no file data
+	Gaps []Gap
+	// Contiguous is true when there are no gaps and no files overlap
+	Contiguous bool
+}
+
+// FileExtent is the region occupied by one file
+type FileExtent struct {
+	Index uint16
+	Name  string
+	Start uint64
+	End   uint64
+}
+
+// Gap is an unused region, End exclusive
+type Gap struct {
+	Start uint64
+	End   uint64
+}
+
+// LayoutReport returns the on-disk arrangement of the files in the PFS0
+func (p *PFS0) LayoutReport() (*Layout, error) {
+	layout := &Layout{ContentBase: p.contentBase(), Contiguous: true}
+	pos := layout.ContentBase
+	for _, i := range p.indicesByOffset() {
+		offset, size, err := p.fileRegion(i)
+		if err != nil {
+			return nil, err
+		}
+		layout.Files = append(layout.Files, FileExtent{i, p.Files[i].Name, offset, offset + size})
+		switch {
+		case offset > pos:
+			layout.Gaps = append(layout.Gaps, Gap{pos, offset})
+			layout.Contiguous = false
+		case offset < pos:
+			layout.Contiguous = false
+		}
+		if offset+size > pos {
+			pos = offset + size
+		}
+	}
+	return layout, nil
+}
+
+// IsContiguous reports whether the files are stored back to back from the
+// start of the content with no padding between them or overlap, as
+// LayoutReport's Contiguous does but without checking the files fit in the
+// PFS0
+func (p *PFS0) IsContiguous() bool {
+	var pos uint64
+	for _, i := range p.indicesByOffset() {
+		f := p.Files[i]
+		if f.StartOffset != pos {
+			return false
+		}
+		pos += f.Size
+	}
+	return true
+}
+
+// AliasedEntries returns each pair of indices, lower first, whose files have
+// the same StartOffset and Size and so share their bytes. Packers sometimes
+// do this on purpose to deduplicate content, but it can also mean a corrupt
+// entry table. Empty files are not reported since they hold no bytes.
+func (p *PFS0) AliasedEntries() [][2]uint16 {
+	byRegion := make(map[[2]uint64][]uint16)
+	var pairs [][2]uint16
+	for i, f := range p.Files {
+		if f.Size == 0 {
+			continue
+		}
+		region := [2]uint64{f.StartOffset, f.Size}
+		for _, j := range byRegion[region] {
+			pairs = append(pairs, [2]uint16{j, uint16(i)})
+		}
+		byRegion[region] = append(byRegion[region], uint16(i))
+	}
+	return pairs
+}
+
+// contentEnd returns the absolute offset just past the end of the last file,
+// or the content base when the PFS0 holds no files. Entries that overlap the
+// header, wrap around or run past the end of the file are an error rather
+// than being measured, as their data could otherwise be taken for a trailer.
+func (p *PFS0) contentEnd() (uint64, error) {
+	end := p.contentBase()
+	for i := range p.Files {
+		offset, size, err := p.fileRegion(uint16(i))
+		if err != nil {
+			return 0, err
+		}
+		if fileEnd := offset + size; fileEnd > end {
+			end = fileEnd
+		}
+	}
+	return end, nil
+}
+
+// FilesByOffset returns a copy of Files sorted by where each file is stored
+func (p *PFS0) FilesByOffset() []File {
+	files := make([]File, 0, len(p.Files))
+	for _, i := range p.indicesByOffset() {
+		files = append(files, p.Files[i])
+	}
+	return files
+}
+
+// FilesInReadOrder returns a copy of Files in the order that reads the
+// archive front to back without seeking backwards, which is the order
+// ExtractAllSequential extracts them in. Files sharing an offset keep their
+// entry order.
+func (p *PFS0) FilesInReadOrder() []File {
+	return p.FilesByOffset()
+}
+
+// indicesByOffset returns the indices of Files in order of StartOffset
+func (p *PFS0) indicesByOffset() []uint16 {
+	indices := make([]uint16, len(p.Files))
+	for i := range indices {
+		indices[i] = uint16(i)
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		return p.Files[indices[a]].StartOffset < p.Files[indices[b]].StartOffset
+	})
+	return indices
+}
+
+// TrailingBytes returns how many bytes follow the end of the last file. It
+// returns 0 when an entry is out of bounds, as Validate reports.
+func (p *PFS0) TrailingBytes() uint64 {
+	if end, err := p.contentEnd(); err == nil && end < p.Size {
+		return p.Size - end
+	}
+	return 0
+}
+
+// Trim writes the PFS0 to w without any data following the end of the last file
+func (p *PFS0) Trim(w io.Writer) error {
+	end, err := p.contentEnd()
+	if err != nil {
+		return err
+	}
+
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
+	r, release := p.sequentialReader(fileHandle, 0, end)
+	defer release()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// WriteTo copies the whole underlying file to w, from its first byte to Size,
+// so a PFS0 can feed any io.WriterTo consumer. For an XCI this includes the
+// gamecard header and every partition, not just the root HFS0 at BaseOffset.
+func (p *PFS0) WriteTo(w io.Writer) (int64, error) {
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return 0, err
+	}
+	defer closeFile()
+
+	r, release := p.sequentialReader(fileHandle, 0, p.Size)
+	defer release()
+	return copyExact(w, r, p.Size, p.Filepath)
+}
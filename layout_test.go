@@ -0,0 +1,91 @@
+package gopfs0
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+func TestTrailerOfWrappingEntry(t *testing.T) {
+	b := pfs0test.BuildFixture(map[string][]byte{"a.nca": make([]byte, 0x20), "b.tik": make([]byte, 0x10)})
+	contentBase := uint64(HeaderBaseSize + 2*EntrySize + binary.LittleEndian.Uint32(b[0x8:]))
+	// The end of a.nca wraps around to 0x1F, which would make everything
+	// after it look like a trailer
+	setEntry(b, 0, ^uint64(0)-contentBase, 0x20)
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := p.TrailingBytes(); n != 0 {
+		t.Errorf("TrailingBytes = 0x%X, want 0", n)
+	}
+	if _, err := p.Trailer(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Trailer error = %v, want ErrTruncated", err)
+	}
+	if _, err := p.TrailerBytes(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("TrailerBytes error = %v, want ErrTruncated", err)
+	}
+	var out bytes.Buffer
+	if err := p.Trim(&out); !errors.Is(err, ErrTruncated) || out.Len() != 0 {
+		t.Errorf("Trim wrote 0x%X bytes, error = %v, want ErrTruncated", out.Len(), err)
+	}
+}
+
+func TestTrailer(t *testing.T) {
+	RegisterMagic("TST0", FormatPFS0)
+	defer func() {
+		magicsMu.Lock()
+		delete(magics, "TST0")
+		magicsMu.Unlock()
+	}()
+	files := map[string][]byte{"a.nca": []byte("abc")}
+	registered := pfs0test.BuildFixture(files)
+	copy(registered, "TST0")
+	large := bytes.Repeat([]byte("vendor"), maxTrailerData)
+	for _, tc := range []struct {
+		name    string
+		trailer []byte
+		kind    TrailerKind
+	}{
+		{"none", nil, TrailerNone},
+		{"padding", bytes.Repeat([]byte{0xFF}, 0x1234), TrailerPadding},
+		{"archive", pfs0test.BuildFixture(files), TrailerArchive},
+		{"registered archive", registered, TrailerArchive},
+		{"unknown", []byte("not a header"), TrailerUnknown},
+		{"large unknown", large, TrailerUnknown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fixture := pfs0test.BuildFixture(files)
+			p := &PFS0{}
+			if err := p.ReadMetadataFromBytes(append(fixture, tc.trailer...)); err != nil {
+				t.Fatal(err)
+			}
+			info, err := p.Trailer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Kind != tc.kind || info.Offset != uint64(len(fixture)) || info.Size != uint64(len(tc.trailer)) {
+				t.Fatalf("Trailer = %v at 0x%X of 0x%X bytes", info.Kind, info.Offset, info.Size)
+			}
+			// Only padding and trailers over the cap leave Data empty
+			wantData := tc.trailer
+			if tc.kind == TrailerPadding || len(tc.trailer) > maxTrailerData {
+				wantData = nil
+			}
+			if !bytes.Equal(info.Data, wantData) {
+				t.Fatalf("Trailer Data holds 0x%X bytes, want 0x%X", len(info.Data), len(wantData))
+			}
+			if all, err := p.TrailerBytes(); err != nil || !bytes.Equal(all, tc.trailer) {
+				t.Fatalf("TrailerBytes = 0x%X bytes, %v, want 0x%X", len(all), err, len(tc.trailer))
+			}
+			var out bytes.Buffer
+			if err := p.Trim(&out); err != nil || !bytes.Equal(out.Bytes(), fixture) {
+				t.Fatalf("Trim = 0x%X bytes, %v, want the 0x%X byte archive", out.Len(), err, len(fixture))
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package gopfs0
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoMatch is returned when no file satisfies a search
+var ErrNoMatch = errors.New("No matching file")
+
+// NameIndex returns a map from file name to index. If a name appears more
+// than once the first index is kept. The map is a copy and safe to modify.
+func (p *PFS0) NameIndex() map[string]uint16 {
+	index := make(map[string]uint16, len(p.Files))
+	for i, f := range p.Files {
+		if _, ok := index[f.Name]; !ok {
+			index[f.Name] = uint16(i)
+		}
+	}
+	return index
+}
+
+// DuplicateNames returns the names shared by more than one file, in the order
+// each first appears. Lookups by name such as NameIndex only find the
+// first of them and extracting to a directory overwrites it with the others.
+func (p *PFS0) DuplicateNames() []string {
+	return duplicateNames(p.Files)
+}
+
+// duplicateNames implements DuplicateNames for files
+func duplicateNames(files []File) []string {
+	seen := make(map[string]int, len(files))
+	var dups []string
+	for _, f := range files {
+		seen[f.Name]++
+		if seen[f.Name] == 2 {
+			dups = append(dups, f.Name)
+		}
+	}
+	return dups
+}
+
+// IndexName returns the file names in index order. The slice is a copy and
+// safe to modify.
+func (p *PFS0) IndexName() []string {
+	names := make([]string, len(p.Files))
+	for i, f := range p.Files {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// FindFileFunc returns the index of the first file, in index order, for which
+// pred returns true. ok is false when no file matches.
+func (p *PFS0) FindFileFunc(pred func(File) bool) (ind uint16, ok bool) {
+	for i, f := range p.Files {
+		if pred(f) {
+			return uint16(i), true
+		}
+	}
+	return 0, false
+}
+
+// compoundExtensions are multi-part extensions counted as one by
+// ExtensionCounts
+var compoundExtensions = []string{"cnmt.nca", "cnmt.ncz", "cnmt.xml", "nacp.xml", "legalinfo.xml", "programinfo.xml"}
+
+// ExtensionCounts returns how many files there are of each extension, such as
+// "nca" or "tik". Known compound extensions like "cnmt.nca" are counted as
+// themselves rather than under their last part. Extensions are lower cased
+// and files without one are counted under "".
+func (p *PFS0) ExtensionCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, f := range p.Files {
+		counts[fileExtension(f.Name)]++
+	}
+	return counts
+}
+
+// fileExtension returns the lower cased extension of name without the dot,
+// preferring a compound extension when one matches
+func fileExtension(name string) string {
+	name = strings.ToLower(name)
+	for _, ext := range compoundExtensions {
+		if strings.HasSuffix(name, "."+ext) {
+			return ext
+		}
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package gopfs0
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+// duplicateFixture returns a PFS0 whose entries are named a.nca, a.nca,
+// c.nca, a.nca and c.nca, by pointing later entries at earlier names
+func duplicateFixture() []byte {
+	b := pfs0test.BuildFixture(map[string][]byte{
+		"a.nca": []byte("1"), "b.nca": []byte("2"), "c.nca": []byte("3"), "d.nca": []byte("4"), "e.nca": []byte("5"),
+	})
+	cName := binary.LittleEndian.Uint32(b[HeaderBaseSize+EntrySize*2+0x10:])
+	setNameOffset(b, 1, 0)
+	setNameOffset(b, 3, 0)
+	setNameOffset(b, 4, cName)
+	return b
+}
+
+func TestDuplicateNames(t *testing.T) {
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(duplicateFixture()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.DuplicateNames(), []string{"a.nca", "c.nca"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("DuplicateNames = %q, want %q", got, want)
+	}
+	// Lookups by name find the first of each
+	if ind := p.NameIndex()["a.nca"]; ind != 0 {
+		t.Fatalf("NameIndex found a.nca at %d, want 0", ind)
+	}
+
+	unique := &PFS0{Strict: true}
+	if err := unique.ReadMetadataFromBytes(pfs0test.BuildFixture(map[string][]byte{"a.nca": nil, "b.nca": nil})); err != nil {
+		t.Fatal(err)
+	}
+	if dups := unique.DuplicateNames(); dups != nil {
+		t.Fatalf("DuplicateNames of unique names = %q", dups)
+	}
+}
+
+func TestStrictRejectsDuplicateNames(t *testing.T) {
+	p := &PFS0{Strict: true}
+	err := p.ReadMetadataFromBytes(duplicateFixture())
+	if !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("ReadMetadataFromBytes error = %v, want ErrDuplicateName", err)
+	}
+	if want := "Duplicate file name: a.nca, c.nca"; err.Error() != want {
+		t.Fatalf("ReadMetadataFromBytes error = %q, want %q", err, want)
+	}
+	if p.Files != nil {
+		t.Fatalf("Files holds %d entries after a failed parse", len(p.Files))
+	}
+
+	// Duplicates are not checked when names are skipped, as every name is
+	// empty then
+	path := writeFixtureFile(t, map[string][]byte{"a.nca": nil, "b.nca": nil})
+	p = NewPFS0(path)
+	p.Strict = true
+	if err := p.ReadMetadataNoNames(); err != nil {
+		t.Fatalf("ReadMetadataNoNames error = %v", err)
+	}
+}
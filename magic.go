@@ -0,0 +1,75 @@
+package gopfs0
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Format is the entry table layout of a container
+type Format int
+
+const (
+	// FormatPFS0 has 0x18 byte entries
+	FormatPFS0 Format = iota
+	// FormatHFS0 has 0x40 byte entries carrying a hash of each file
+	FormatHFS0
+)
+
+// entrySize returns the size of one entry table record of the format
+func (f Format) entrySize() uint64 {
+	if f == FormatHFS0 {
+		return HFS0EntrySize
+	}
+	return EntrySize
+}
+
+// headerLen returns the size of the fixed header plus an entry table of
+// fileCount records of the format
+func (f Format) headerLen(fileCount uint32) uint64 {
+	return HeaderBaseSize + f.entrySize()*uint64(fileCount)
+}
+
+var (
+	magicsMu sync.RWMutex
+	// magics holds the formats registered with RegisterMagic
+	magics = map[string]Format{}
+)
+
+// RegisterMagic makes ReadMetadata and the other parsers accept containers
+// starting with magic, a 4 byte string, and parse them with the layout of
+// format. It is meant for homebrew variants that change only the magic.
+// Nothing but "PFS0" and "HFS0" is accepted unless registered. It panics if
+// magic is not 4 bytes or is one of the built in magics.
+func RegisterMagic(magic string, format Format) {
+	if len(magic) != 4 {
+		panic(fmt.Sprintf("gopfs0: magic %q is not 4 bytes", magic))
+	}
+	if _, builtin := builtinFormat(magic); builtin {
+		panic(fmt.Sprintf("gopfs0: magic %q is built in", magic))
+	}
+	magicsMu.Lock()
+	defer magicsMu.Unlock()
+	magics[magic] = format
+}
+
+// lookupFormat returns the format of containers starting with m
+func lookupFormat(m string) (Format, bool) {
+	if f, ok := builtinFormat(m); ok {
+		return f, true
+	}
+	magicsMu.RLock()
+	defer magicsMu.RUnlock()
+	f, ok := magics[m]
+	return f, ok
+}
+
+// builtinFormat returns the format of the built in magics
+func builtinFormat(m string) (Format, bool) {
+	switch m {
+	case magic:
+		return FormatPFS0, true
+	case hfs0Magic:
+		return FormatHFS0, true
+	}
+	return 0, false
+}
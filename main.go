@@ -1,37 +1,200 @@
 package gopfs0
 
 import (
-	"encoding/binary"
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"path"
 	"strings"
+	"sync"
+
+	"github.com/nosmokingbandit/gopfs0/internal/pfs0fmt"
 )
 
 var err error
 
 const (
 	chunkSize = 0x800 // 2048
-	magic     = "PFS0"
+	magic     = pfs0fmt.Magic
+	// hfs0Magic identifies the HFS0 variant of PFS0 used by gamecard images
+	hfs0Magic = "HFS0"
 )
 
 // NewPFS0 creates a new PFS0 object from given filepath
 func NewPFS0(filepath string) *PFS0 {
-	return &PFS0{Filepath: filepath, Basename: strings.Split(path.Base(filepath), ".")[0]}
+	return &PFS0{Filepath: filepath, Basename: basename(filepath)}
+}
+
+// NewPFS0FromReaderAt creates a PFS0 object backed by r, which holds size bytes,
+// and reads its metadata. name is used for Filepath and Basename only.
+func NewPFS0FromReaderAt(r io.ReaderAt, size int64, name string) (*PFS0, error) {
+	p := &PFS0{Filepath: name, Basename: basename(name), Size: uint64(size), src: r}
+	if err := p.parseMetadata(r); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewPFS0FromFile creates a PFS0 object that reads from an already open file
+// and reads its metadata. The PFS0 takes ownership of f and closes it on Close,
+// or straight away if f cannot be parsed.
+func NewPFS0FromFile(f *os.File) (*PFS0, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		log.Print(err)
+		f.Close()
+		return nil, err
+	}
+	p, err := NewPFS0FromReaderAt(f, fi.Size(), f.Name())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.closer = f
+	return p, nil
+}
+
+// Close releases the file the PFS0 was created from, if it owns one
+func (p *PFS0) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	err := p.closer.Close()
+	p.closer = nil
+	return err
+}
+
+// basename returns the file name of filepath up to the first dot
+func basename(filepath string) string {
+	return strings.Split(path.Base(filepath), ".")[0]
 }
 
 // PFS0 struct to represent PFS0 filesystem of NSP
 type PFS0 struct {
-	Filepath  string
-	Basename  string
-	Size      uint64
-	HeaderLen uint16
-	Files     []pfs0File
+	Filepath string
+	Basename string
+	Size     uint64
+	// Magic is "PFS0", or "HFS0" for gamecard partitions
+	Magic string
+	// BaseOffset is where the PFS0 header starts in the file. It is zero for
+	// an NSP and the offset of the root HFS0 partition for an XCI.
+	BaseOffset uint64
+	HeaderLen  uint32
+	// StringTableSize is the length of the file name table that follows the
+	// entries. File data starts immediately after it.
+	StringTableSize uint32
+	Files           []File
+
+	// OnEvent, when set, is called at each step of parsing and reading so the
+	// PFS0 can be traced. Events are "header_read", "file_count",
+	// "entry_parsed", "validation_failed", "ticket_read", "nca_reader_open" and
+	// "nca_reader_done".
+	OnEvent func(event string, fields map[string]any)
+
+	// SequentialBufferSize sets the buffer used when a file is read front to
+	// back, e.g. by NcaReader and ExtractAll. Zero uses a 64 KiB buffer and a
+	// negative value disables buffering. It is ignored when BufferPool is set.
+	SequentialBufferSize int
+	// BufferPool, when set, supplies the buffers used when a file is read
+	// front to back so they can be shared with other PFS0s, such as by a
+	// server extracting many NSPs at once. Nil uses a pool internal to the
+	// package, or fresh buffers when SequentialBufferSize is set.
+	BufferPool BufferPool
+
+	// Strict makes ReadMetadata reject headers that parse but would break
+	// name based lookup and extraction, namely ones naming two files the same
+	Strict bool
+
+	// src, when set, is read instead of opening Filepath
+	src io.ReaderAt
+	// closer releases src on Close
+	closer io.Closer
+	// xci is set when the file is a gamecard image, with xciOffset holding
+	// where its header starts
+	xci       bool
+	xciOffset uint64
+	// mapped is set once UseMmap has replaced src with a mapping of Filepath
+	mapped bool
+
+	// hashCache holds SHA-256 digests keyed by absolute offset and size once
+	// EnableHashCache is called
+	hashCache   map[[2]uint64][32]byte
+	hashCacheMu sync.Mutex
+}
+
+// ErrTruncated is returned when the header describes more data than is present
+var ErrTruncated = errors.New("PFS0 is truncated")
+
+// ErrInvalidNameOffset is returned when an entry's name does not start inside
+// the string table
+var ErrInvalidNameOffset = errors.New("Invalid name offset")
+
+// ErrDuplicateName is returned in strict mode when two files share a name
+var ErrDuplicateName = errors.New("Duplicate file name")
+
+// ErrTooLarge is returned when data is too large to be read into memory on
+// this platform. Use a streaming reader such as NcaReader instead.
+var ErrTooLarge = errors.New("Too large to read into memory")
+
+// allocSize converts n to an int for allocating a buffer, failing when n does
+// not fit in an int (only possible on 32-bit platforms)
+func allocSize(n uint64) (int, error) {
+	if n > math.MaxInt {
+		return 0, fmt.Errorf("%w: 0x%X bytes", ErrTooLarge, n)
+	}
+	return int(n), nil
+}
+
+// contentBase returns the absolute offset that file StartOffsets are relative to
+func (p *PFS0) contentBase() uint64 {
+	return p.BaseOffset + uint64(p.HeaderLen) + uint64(p.StringTableSize)
+}
+
+// emit sends an event to OnEvent if it is set
+func (p *PFS0) emit(event string, fields map[string]any) {
+	if p.OnEvent != nil {
+		p.OnEvent(event, fields)
+	}
+}
+
+// invalid reports err as a validation failure and returns it
+func (p *PFS0) invalid(err error) error {
+	if p.OnEvent != nil {
+		p.OnEvent("validation_failed", map[string]any{"error": err})
+	}
+	return err
+}
+
+// open returns a reader for the underlying NSP along with a function that
+// releases it once the caller is done. The reader is only ever used through
+// ReadAt, directly or via section readers, so concurrent reads of the same or
+// different files never share a position. A handle cached here would need to
+// keep that property, e.g. seekReaderAt serializes its seeks.
+func (p *PFS0) open() (io.ReaderAt, func() error, error) {
+	if p.src != nil {
+		return p.src, func() error { return nil }, nil
+	}
+	fileHandle, err := os.Open(p.Filepath)
+	if err != nil {
+		log.Println(err)
+		return nil, nil, err
+	}
+	return fileHandle, fileHandle.Close, nil
 }
 
 // ReadMetadata reads metadata from NSP header and populates PFS0 fields
 func (p *PFS0) ReadMetadata() error {
+	return p.readMetadataFile(parseOptions{})
+}
+
+// readMetadataFile opens Filepath, sets Size and parses the header with opts.
+// Files is left empty if the file cannot be opened or parsed.
+func (p *PFS0) readMetadataFile(opts parseOptions) error {
+	p.Files = nil
 	fileHandle, err := os.Open(p.Filepath)
 	if err != nil {
 		log.Println(err)
@@ -39,8 +202,6 @@ func (p *PFS0) ReadMetadata() error {
 	}
 	defer fileHandle.Close()
 
-	fileHandle.Seek(0, 0)
-
 	fi, err := fileHandle.Stat()
 	if err != nil {
 		log.Print(err)
@@ -48,83 +209,345 @@ func (p *PFS0) ReadMetadata() error {
 	}
 	p.Size = uint64(fi.Size())
 
-	nspHeader := make([]byte, 0x20)
-	_, err = fileHandle.Read(nspHeader)
-	if string(nspHeader[:0x4]) != magic {
-		return errors.New("Invalid NSP header. Expected 'PFS0', got '" + string(nspHeader[:0x4]) + "'")
+	return p.parse(fileHandle, opts)
+}
+
+// ReadMetadataFromBytes parses an in-memory NSP and populates PFS0 fields.
+// Later reads are served from b, which must not be modified.
+func (p *PFS0) ReadMetadataFromBytes(b []byte) error {
+	p.src = bytes.NewReader(b)
+	p.Size = uint64(len(b))
+	return p.parseMetadata(p.src)
+}
+
+// ReadMetadataPartial reads metadata like ReadMetadata, but salvages what it
+// can from a damaged header. Entries are parsed in header order and parsing
+// stops at the first entry whose record or name cannot be read, or whose name
+// offset is invalid. Files holds every entry before that one, which are the
+// same entries ReadMetadata would have returned for an intact header, and the
+// returned error is a *ParseError giving the index parsing stopped at.
+// Errors in the fixed header leave Files empty.
+func (p *PFS0) ReadMetadataPartial() ([]File, error) {
+	err := p.readMetadataFile(parseOptions{partial: true})
+	return p.Files, err
+}
+
+// ReadMetadataNoNames reads metadata like ReadMetadata but does not read the
+// string table, leaving every Name empty. It is meant for scans that only
+// need offsets and sizes. Name offsets are not validated.
+func (p *PFS0) ReadMetadataNoNames() error {
+	return p.readMetadataFile(parseOptions{skipNames: true})
+}
+
+// ReadOffsetsOnly reads metadata with ReadMetadataNoNames and returns the
+// offset and size of every file in index order. Call ReadMetadata to fill in
+// the names later.
+func (p *PFS0) ReadOffsetsOnly() ([]struct{ Offset, Size uint64 }, error) {
+	if err := p.ReadMetadataNoNames(); err != nil {
+		return nil, err
+	}
+	extents := make([]struct{ Offset, Size uint64 }, len(p.Files))
+	for i, f := range p.Files {
+		extents[i].Offset = f.StartOffset
+		extents[i].Size = f.Size
 	}
+	return extents, nil
+}
 
-	fileCount := binary.LittleEndian.Uint16(nspHeader[0x4:0x8])
-	p.HeaderLen = 0x10 + (0x18 * fileCount)
+// ParseError is returned by ReadMetadataPartial when parsing stopped partway
+// through the entry table. Entries before Index were parsed successfully.
+type ParseError struct {
+	Index uint32
+	Err   error
+}
 
-	stringsLen := binary.LittleEndian.Uint16(nspHeader[0x8:0xC])
-	fileNamesBuffer := make([]byte, stringsLen)
-	fileHandle.Seek(int64(p.HeaderLen), 0)
-	fileHandle.Read(fileNamesBuffer)
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Parsing stopped at file %d: %v", e.Index, e.Err)
+}
 
-	// Individual file metadata
-	p.Files = make([]pfs0File, fileCount)
-	for i := uint16(0); i < fileCount; i++ {
-		fileHandle.Seek(int64(0x10+(0x18*i)), 0)
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseMetadata reads the header, entry table and string table from r.
+// p.Size must already hold the size of r. On error Files is left empty.
+func (p *PFS0) parseMetadata(r io.ReaderAt) error {
+	return p.parse(r, parseOptions{})
+}
+
+// parseOptions adjusts how parse reads the header
+type parseOptions struct {
+	// partial keeps the entries before the first unreadable one in Files and
+	// returns a *ParseError
+	partial bool
+	// skipNames leaves every Name empty without reading the string table
+	skipNames bool
+}
+
+// parse implements parseMetadata and readMetadataFile
+func (p *PFS0) parse(r io.ReaderAt, opts parseOptions) error {
+	partial := opts.partial
+	p.BaseOffset = 0
+	p.xci = false
+	p.Files = nil
 
-		fileMetaData := make([]byte, 0x18)
-		_, err = fileHandle.Read(fileMetaData)
-		if err != nil {
-			log.Print(err)
-			return err
+	// Fail clearly on files too short to hold even the fixed header, rather
+	// than on whatever the short read returns
+	if p.Size < HeaderBaseSize {
+		return p.invalid(fmt.Errorf("%w: file is 0x%X bytes, smaller than the 0x%X byte header", ErrTruncated, p.Size, HeaderBaseSize))
+	}
+	nspHeader := make([]byte, HeaderBaseSize)
+	if _, err := r.ReadAt(nspHeader, 0); err != nil {
+		return p.invalid(fmt.Errorf("%w: unable to read header: %v", ErrTruncated, err))
+	}
+	p.Magic = string(nspHeader[:0x4])
+	// Gamecard images keep an HFS0 further into the file. Anything else with
+	// an unknown magic is left for parseHeader to reject.
+	if _, ok := lookupFormat(p.Magic); !ok && p.detectXCI(r) {
+		if p.BaseOffset > p.Size || p.Size-p.BaseOffset < HeaderBaseSize {
+			return p.invalid(fmt.Errorf("%w: XCI root partition at 0x%X does not fit in the 0x%X byte file", ErrTruncated, p.BaseOffset, p.Size))
+		}
+		if _, err := r.ReadAt(nspHeader, int64(p.BaseOffset)); err != nil {
+			return p.invalid(fmt.Errorf("%w: unable to read XCI root partition: %v", ErrTruncated, err))
+		}
+		p.Magic = string(nspHeader[:0x4])
+		if p.Magic != hfs0Magic {
+			return p.invalid(errors.New("Invalid XCI root partition. Expected 'HFS0', got '" + p.Magic + "'"))
+		}
+	}
+	hdr, format, headerLen, err := parseHeader(nspHeader)
+	if err != nil {
+		return p.invalid(err)
+	}
+	p.emit("header_read", map[string]any{"magic": p.Magic, "size": p.Size, "base_offset": p.BaseOffset})
+	entrySize := format.entrySize()
+	fileCount := hdr.FileCount
+	p.StringTableSize = hdr.StringTableSize
+	p.emit("file_count", map[string]any{"count": fileCount, "string_table_size": p.StringTableSize})
+
+	// Check the declared tables fit before allocating anything for them. A
+	// partial parse reads whatever portion of them is present instead.
+	available := p.Size - p.BaseOffset
+	entryCount := fileCount
+	nameTableLen := uint64(p.StringTableSize)
+	if headerLen+uint64(p.StringTableSize) > available {
+		if !partial {
+			return p.invalid(fmt.Errorf("%w: header declares %d files and a 0x%X byte string table, but file is only 0x%X bytes",
+				ErrTruncated, fileCount, p.StringTableSize, p.Size))
+		}
+		if n := (available - HeaderBaseSize) / entrySize; n < uint64(fileCount) {
+			entryCount = uint32(n)
 		}
+		nameTableLen = 0
+		if available > headerLen {
+			nameTableLen = available - headerLen
+		}
+		if nameTableLen > uint64(p.StringTableSize) {
+			nameTableLen = uint64(p.StringTableSize)
+		}
+	}
+	if opts.skipNames {
+		nameTableLen = 0
+	}
+	if _, err := allocSize(entrySize*uint64(entryCount) + nameTableLen); err != nil {
+		return p.invalid(err)
+	}
+	p.HeaderLen = uint32(headerLen)
 
-		fileOffset := binary.LittleEndian.Uint64(fileMetaData[0:8])
-		fileSize := binary.LittleEndian.Uint64(fileMetaData[8:16])
+	entryTable := make([]byte, entrySize*uint64(entryCount))
+	if _, err := r.ReadAt(entryTable, int64(p.BaseOffset)+HeaderBaseSize); err != nil {
+		return p.invalid(fmt.Errorf("%w: unable to read file entries: %v", ErrTruncated, err))
+	}
+	entries, err := decodeEntries(entryTable, entryCount, format == FormatHFS0)
+	if err != nil {
+		return p.invalid(err)
+	}
+	fileNamesBuffer := make([]byte, nameTableLen)
+	if _, err := r.ReadAt(fileNamesBuffer, int64(p.BaseOffset)+int64(p.HeaderLen)); err != nil {
+		return p.invalid(fmt.Errorf("%w: unable to read string table: %v", ErrTruncated, err))
+	}
+
+	// Individual file metadata
+	files := make([]File, 0, entryCount)
+	stop := func(i uint32, err error) error {
+		if !partial {
+			return p.invalid(err)
+		}
+		p.Files = files
+		return p.invalid(&ParseError{Index: i, Err: err})
+	}
+	for i := uint32(0); i < entryCount; i++ {
+		fileOffset := entries[i].Offset
+		fileSize := entries[i].Size
+		nameOffset := entries[i].NameOffset
+		if opts.skipNames {
+			files = append(files, File{StartOffset: fileOffset, Size: fileSize, Reserved: entries[i].Reserved})
+			p.emit("entry_parsed", map[string]any{"index": i, "name": "", "offset": fileOffset, "size": fileSize})
+			continue
+		}
+		if err := checkNameOffset(int(i), nameOffset, p.StringTableSize); err != nil {
+			return stop(i, err)
+		}
+		terminated := false
 		var nameBytes []byte
-		for _, b := range fileNamesBuffer[binary.LittleEndian.Uint16(fileMetaData[16:20]):] {
+		nameStart := uint64(nameOffset)
+		if nameStart > nameTableLen {
+			nameStart = nameTableLen
+		}
+		for _, b := range fileNamesBuffer[nameStart:] {
 			if b == 0x0 {
+				terminated = true
 				break
 			} else {
 				nameBytes = append(nameBytes, b)
 			}
 		}
+		if !terminated && nameTableLen < uint64(p.StringTableSize) {
+			return stop(i, fmt.Errorf("%w: name of file %d is past the end of the file", ErrTruncated, i))
+		}
 
-		p.Files[i] = pfs0File{fileOffset, fileSize, string(nameBytes)}
+		files = append(files, File{fileOffset, fileSize, string(nameBytes), entries[i].Reserved})
+		p.emit("entry_parsed", map[string]any{"index": i, "name": files[i].Name, "offset": fileOffset, "size": fileSize})
+	}
+	if entryCount < fileCount {
+		return stop(entryCount, fmt.Errorf("%w: entry table ends after %d of %d files", ErrTruncated, entryCount, fileCount))
 	}
+	if p.Strict && !opts.skipNames {
+		if dups := duplicateNames(files); len(dups) > 0 {
+			return p.invalid(fmt.Errorf("%w: %s", ErrDuplicateName, strings.Join(dups, ", ")))
+		}
+	}
+	p.Files = files
 	return nil
 }
 
+// EntryBytes returns the raw entry table record of the file with the given
+// index, including the reserved field the parser does not interpret. Records
+// are 0x18 bytes for PFS0 and 0x40 bytes for HFS0.
+func (p *PFS0) EntryBytes(ind uint16) ([]byte, error) {
+	if int(ind) >= len(p.Files) {
+		return nil, p.invalid(fmt.Errorf("File index %d out of range", ind))
+	}
+	return p.readAt(p.BaseOffset+HeaderBaseSize+p.entrySize()*uint64(ind), p.entrySize())
+}
+
+// entrySize returns the size of one entry table record
+func (p *PFS0) entrySize() uint64 {
+	return p.format().entrySize()
+}
+
+// format returns the entry layout for p.Magic
+func (p *PFS0) format() Format {
+	f, _ := lookupFormat(p.Magic)
+	return f
+}
+
 // ReadTik reads ticket file in PFS0 into byte array
 func (p *PFS0) ReadTik() ([]byte, error) {
-	var tikInd int
+	tikInd, ticket, err := p.readAuxFile(".tik", "ticket")
+	if err != nil {
+		return nil, err
+	}
+	p.emit("ticket_read", map[string]any{"index": tikInd, "name": p.Files[tikInd].Name, "size": len(ticket)})
+	return ticket, nil
+}
+
+// ReadCert reads the certificate chain file in PFS0 into byte array
+func (p *PFS0) ReadCert() ([]byte, error) {
+	_, cert, err := p.readAuxFile(".cert", "certificate")
+	return cert, err
+}
+
+// readAuxFile reads the first file whose name ends in ext. Tickets and
+// certificates are small enough that a truncated copy is easy to miss, so the
+// error says exactly how much of the file is missing.
+func (p *PFS0) readAuxFile(ext, kind string) (int, []byte, error) {
+	ind := -1
 	for i, f := range p.Files {
-		if f.Name[len(f.Name)-3:] == "tik" {
-			tikInd = i
+		if strings.HasSuffix(f.Name, ext) {
+			ind = i
 			break
 		}
 	}
+	if ind < 0 {
+		return 0, nil, p.invalid(fmt.Errorf("No %s found in PFS0", kind))
+	}
 
-	fileHandle, err := os.Open(p.Filepath)
+	f := p.Files[ind]
+	offset := p.contentBase() + f.StartOffset
+	if offset >= f.StartOffset && offset+f.Size >= offset && offset+f.Size > p.Size {
+		remaining := uint64(0)
+		if offset < p.Size {
+			remaining = p.Size - offset
+		}
+		return 0, nil, p.invalid(fmt.Errorf("%w: %s %s declares 0x%X bytes but only 0x%X are present",
+			ErrTruncated, kind, f.Name, f.Size, remaining))
+	}
+	content, err := p.readFile(uint16(ind))
+	if err != nil {
+		return 0, nil, err
+	}
+	return ind, content, nil
+}
+
+// fileRegion returns the absolute offset and size of the file with the given
+// index, checking that it lies within the PFS0
+func (p *PFS0) fileRegion(ind uint16) (uint64, uint64, error) {
+	if int(ind) >= len(p.Files) {
+		return 0, 0, p.invalid(fmt.Errorf("File index %d out of range", ind))
+	}
+	f := p.Files[ind]
+	if p.FileOverlapsHeader(ind) {
+		return 0, 0, p.invalid(fmt.Errorf("%w: %s starts at 0x%X", ErrOverlapsHeader, f.Name, f.StartOffset))
+	}
+	offset := p.contentBase() + f.StartOffset
+	if offset+f.Size < offset || offset+f.Size > p.Size {
+		return 0, 0, p.invalid(fmt.Errorf("%w: %s extends past end of file", ErrTruncated, f.Name))
+	}
+	return offset, f.Size, nil
+}
+
+// readFile reads the whole file with the given index into memory
+func (p *PFS0) readFile(ind uint16) ([]byte, error) {
+	offset, size, err := p.fileRegion(ind)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	defer fileHandle.Close()
+	return p.readAt(offset, size)
+}
 
-	fileHandle.Seek(0, 0)
-	tikOffset := uint64(p.HeaderLen) + uint64(p.Files[tikInd].StartOffset)
-	fileHandle.Seek(int64(tikOffset), 0)
-	ticket := make([]byte, p.Files[tikInd].Size)
-	_, err = fileHandle.Read(ticket)
+// readAt reads size bytes at the given absolute offset of the underlying NSP
+func (p *PFS0) readAt(offset, size uint64) ([]byte, error) {
+	n, err := allocSize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+
+	content := make([]byte, n)
+	_, err = fileHandle.ReadAt(content, int64(offset))
 	if err != nil {
 		log.Print(err)
 		return nil, err
 	}
-	return ticket, nil
+	return content, nil
 }
 
 // NcaReader returns a channel that reads 0x800byte chunks from the file with
 //	the given index in the PFS0 file system
 func (p *PFS0) NcaReader(ind uint16) (<-chan chunk, error) {
-	fileHandle, err := os.Open(p.Filepath)
+	currentOffset, remaining, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, err
+	}
+
+	source, closeFile, err := p.open()
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
 
@@ -132,33 +555,100 @@ func (p *PFS0) NcaReader(ind uint16) (<-chan chunk, error) {
 
 	file := p.Files[ind]
 
-	currentOffset := uint64(p.HeaderLen) + uint64(file.StartOffset)
-	remaining := file.Size
-	fileHandle.Seek(int64(currentOffset), 0)
+	fileHandle, release := p.sequentialReader(source, currentOffset, remaining)
+
+	p.emit("nca_reader_open", map[string]any{"index": ind, "name": file.Name, "offset": currentOffset, "size": remaining})
 
 	go func() {
 		defer close(c)
-		defer fileHandle.Close()
+		defer closeFile()
+		defer release()
+		defer p.emit("nca_reader_done", map[string]any{"index": ind, "name": file.Name})
 		for remaining > 0 {
 			chnk := chunk{}
 
-			chnk.Content = make([]byte, chunkSize)
-			chnk.Size = chunkSize
+			// Never allocate more than a chunk, however large the entry claims to be
+			n := uint64(chunkSize)
 			if remaining < chunkSize {
-				chnk.Content = make([]byte, remaining)
-				chnk.Size = remaining
+				n = remaining
 			}
+			chnk.Content = make([]byte, n)
 
-			r, err := fileHandle.Read(chnk.Content)
-			chnk.Err = err
+			r, err := io.ReadFull(fileHandle, chnk.Content)
+			chnk.Content = chnk.Content[:r]
+			chnk.Size = uint64(r)
 			currentOffset += uint64(r)
 			remaining -= uint64(r)
+			chnk.Remaining = int64(remaining)
+			if err != nil {
+				// The source ended early or failed, so stop rather than spin
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					err = fmt.Errorf("%w: %s ended with 0x%X bytes unread", ErrTruncated, file.Name, remaining)
+				}
+				chnk.Err = err
+				c <- chnk
+				return
+			}
 			c <- chnk
 		}
 	}()
 	return c, nil
 }
 
+// ErrStreamTooLarge is returned by StreamReader when the stream holds more
+// than the allowed number of bytes
+var ErrStreamTooLarge = errors.New("Stream exceeds maximum size")
+
+// StreamReader returns a channel that reads 0x800byte chunks from r until it
+// ends, for sources such as pipes whose size is not known up front. Chunks
+// report a Remaining of -1, except the last which reports 0 and may be
+// short. If maxBytes is positive and r holds more than maxBytes bytes, the
+// chunk that crosses the limit is cut at it and carries ErrStreamTooLarge.
+func StreamReader(r io.Reader, maxBytes int64) <-chan chunk {
+	c := make(chan chunk)
+	go func() {
+		defer close(c)
+		var total int64
+		// Each chunk is held back until the next read shows whether it was
+		// the last one
+		var pending *chunk
+		for {
+			chnk := chunk{Content: make([]byte, chunkSize), Remaining: -1}
+			n, err := io.ReadFull(r, chnk.Content)
+			if maxBytes > 0 && total+int64(n) > maxBytes {
+				n = int(maxBytes - total)
+				err = fmt.Errorf("%w: more than 0x%X bytes", ErrStreamTooLarge, maxBytes)
+			}
+			total += int64(n)
+			chnk.Content = chnk.Content[:n]
+			chnk.Size = uint64(n)
+
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				if n == 0 && pending != nil {
+					chnk = *pending
+				} else if pending != nil {
+					c <- *pending
+				}
+				chnk.Remaining = 0
+				if chnk.Size > 0 {
+					c <- chnk
+				}
+				return
+			}
+			if pending != nil {
+				c <- *pending
+			}
+			if err != nil {
+				chnk.Err = err
+				c <- chnk
+				return
+			}
+			pending = &chnk
+		}
+	}()
+	return c
+}
+
 type chunk struct {
 	Size      uint64
 	Remaining int64
@@ -166,8 +656,12 @@ type chunk struct {
 	Err       error
 }
 
-type pfs0File struct {
+// File describes a single file stored in the PFS0
+type File struct {
 	StartOffset uint64
 	Size        uint64
 	Name        string
+	// Reserved is the normally zero reserved field of a PFS0 entry, which
+	// some dumps store data in. It is always zero for HFS0.
+	Reserved uint32
 }
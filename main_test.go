@@ -0,0 +1,215 @@
+package gopfs0
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+// tooLarge is a size that cannot be allocated as a single buffer
+const tooLarge = uint64(math.MaxInt) + 1
+
+func TestAllocSizeTooLarge(t *testing.T) {
+	if _, err := allocSize(tooLarge); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("allocSize(0x%X) error = %v, want ErrTooLarge", tooLarge, err)
+	}
+	if n, err := allocSize(0x10); err != nil || n != 0x10 {
+		t.Fatalf("allocSize(0x10) = %d, %v", n, err)
+	}
+}
+
+func TestReadOversizedFile(t *testing.T) {
+	// The declared sizes fit the mocked Size, so only the allocation guard
+	// stands between the reads and a huge make
+	p := &PFS0{
+		Size:  math.MaxUint64,
+		Files: []File{{StartOffset: 0, Size: tooLarge, Name: "0.tik"}},
+		src:   bytes.NewReader(nil),
+	}
+	if _, err := p.readAt(0, tooLarge); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("readAt error = %v, want ErrTooLarge", err)
+	}
+	if _, err := p.ReadTik(); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("ReadTik error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestParseRejectsUnindexableFileCount(t *testing.T) {
+	// Room for every entry and a one byte string table, so only the count
+	// itself is wrong
+	const count = math.MaxUint16 + 1
+	b := make([]byte, HeaderBaseSize+EntrySize*count+1)
+	copy(b, "PFS0")
+	binary.LittleEndian.PutUint32(b[0x4:], count)
+	binary.LittleEndian.PutUint32(b[0x8:], 1)
+
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(b); err == nil {
+		t.Fatalf("ReadMetadataFromBytes accepted %d files", count)
+	}
+	if p.Files != nil {
+		t.Fatalf("Files holds %d entries after a failed parse", len(p.Files))
+	}
+
+	binary.LittleEndian.PutUint32(b[0x4:], count-1)
+	if err := p.ReadMetadataFromBytes(b); err != nil || len(p.Files) != count-1 {
+		t.Fatalf("ReadMetadataFromBytes with %d files = %d files, %v", count-1, len(p.Files), err)
+	}
+}
+
+// setEntry overwrites the offset and size of entry i in the PFS0 header b
+func setEntry(b []byte, i int, offset, size uint64) {
+	entry := b[HeaderBaseSize+EntrySize*i:]
+	binary.LittleEndian.PutUint64(entry[0x0:], offset)
+	binary.LittleEndian.PutUint64(entry[0x8:], size)
+}
+
+// setNameOffset overwrites the name offset of entry i in the PFS0 header b
+func setNameOffset(b []byte, i int, nameOffset uint32) {
+	binary.LittleEndian.PutUint32(b[HeaderBaseSize+EntrySize*i+0x10:], nameOffset)
+}
+
+func TestParseRejectsBadNameOffset(t *testing.T) {
+	b := pfs0test.BuildFixture(map[string][]byte{"a.nca": []byte("a"), "b.tik": []byte("b")})
+	stringTableSize := binary.LittleEndian.Uint32(b[0x8:])
+	setNameOffset(b, 1, stringTableSize)
+
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(b); !errors.Is(err, ErrInvalidNameOffset) {
+		t.Fatalf("ReadMetadataFromBytes error = %v, want ErrInvalidNameOffset", err)
+	}
+	if p.Files != nil {
+		t.Fatalf("Files holds %d entries after a failed parse", len(p.Files))
+	}
+}
+
+func TestNcaReaderStopsAtEndOfShortSource(t *testing.T) {
+	const declared = 4 << 40 // 4 TiB
+	b := pfs0test.BuildFixture(map[string][]byte{"a.nca": make([]byte, 0x1000)})
+	setEntry(b, 0, 0, declared)
+	// The source claims to be big enough for the entry but holds only the
+	// first 0x1000 bytes of it
+	p, err := NewPFS0FromReaderAt(bytes.NewReader(b), int64(len(b))+declared, "short.nsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	c, err := p.NcaReader(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read uint64
+	var last error
+	for chnk := range c {
+		read += chnk.Size
+		last = chnk.Err
+	}
+	runtime.ReadMemStats(&after)
+
+	if !errors.Is(last, ErrTruncated) {
+		t.Fatalf("last chunk error = %v, want ErrTruncated", last)
+	}
+	if read != 0x1000 {
+		t.Fatalf("read 0x%X bytes, want 0x1000", read)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 16<<20 {
+		t.Fatalf("NcaReader allocated 0x%X bytes for a 0x1000 byte source", alloc)
+	}
+}
+
+func TestReadMetadataVariants(t *testing.T) {
+	path := writeFixtureFile(t, map[string][]byte{"a.nca": []byte("abc"), "b.tik": []byte("de")})
+
+	p := NewPFS0(path)
+	if err := p.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	full := p.Files
+
+	partial, err := NewPFS0(path).ReadMetadataPartial()
+	if err != nil || !reflect.DeepEqual(partial, full) {
+		t.Errorf("ReadMetadataPartial = %+v, %v, want %+v", partial, err, full)
+	}
+
+	p = NewPFS0(path)
+	if err := p.ReadMetadataNoNames(); err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range p.Files {
+		want := full[i]
+		want.Name = ""
+		if f != want {
+			t.Errorf("ReadMetadataNoNames file %d = %+v, want %+v", i, f, want)
+		}
+	}
+
+	p = NewPFS0(path + ".missing")
+	p.Files = full
+	if err := p.ReadMetadata(); err == nil || p.Files != nil {
+		t.Errorf("ReadMetadata of a missing file = %d files, %v", len(p.Files), err)
+	}
+}
+
+func TestReadTikLargerThanFile(t *testing.T) {
+	b := pfs0test.BuildFixture(map[string][]byte{"0.cert": make([]byte, 0x10), "0.tik": make([]byte, 0x10)})
+	// The ticket is stored second, so claim it runs 0x1000 bytes past the end
+	setEntry(b, 1, 0x10, 0x1010)
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(b); err != nil {
+		t.Fatal(err)
+	}
+	_, err := p.ReadTik()
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("ReadTik error = %v, want ErrTruncated", err)
+	}
+	if want := "declares 0x1010 bytes but only 0x10 are present"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("ReadTik error = %q, want it to contain %q", err, want)
+	}
+	if _, err := p.ReadCert(); err != nil {
+		t.Fatalf("ReadCert error = %v", err)
+	}
+}
+
+func TestReadMetadataFileSmallerThanHeader(t *testing.T) {
+	full := pfs0test.BuildFixture(map[string][]byte{"a.nca": []byte("abc"), "b.tik": []byte("de")})
+	headerLen := HeaderBaseSize + 2*EntrySize + int(binary.LittleEndian.Uint32(full[0x8:]))
+	for _, tc := range []struct {
+		name string
+		b    []byte
+		want string
+	}{
+		{"empty", nil, "file is 0x0 bytes, smaller than the 0x10 byte header"},
+		{"magic only", full[:4], "file is 0x4 bytes, smaller than the 0x10 byte header"},
+		{"fixed header only", full[:HeaderBaseSize], "header declares 2 files"},
+		{"string table cut short", full[:headerLen-1], "header declares 2 files"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "short.nsp")
+			if err := os.WriteFile(path, tc.b, 0644); err != nil {
+				t.Fatal(err)
+			}
+			p := NewPFS0(path)
+			err := p.ReadMetadata()
+			if !errors.Is(err, ErrTruncated) {
+				t.Fatalf("ReadMetadata error = %v, want ErrTruncated", err)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("ReadMetadata error = %q, want it to contain %q", err, tc.want)
+			}
+			if p.Files != nil {
+				t.Fatalf("Files holds %d entries after a failed parse", len(p.Files))
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package gopfs0
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+)
+
+// errMmapUnsupported is returned by mmapFile on platforms without memory
+// mapped files
+var errMmapUnsupported = errors.New("Memory mapping is not supported on this platform")
+
+// UseMmap maps the NSP into memory read-only and serves every later read by
+// slicing the mapping. Reads then need no file handles or seeks, so any
+// number of goroutines can read concurrently, as with HashFile calls or
+// ExtractAllParallel. The mapping is released by Close. Where mapping is
+// unsupported an error is returned and the PFS0 keeps reading as before.
+func (p *PFS0) UseMmap() error {
+	f, ok := p.src.(*os.File)
+	if !ok {
+		if p.src != nil {
+			return errors.New("PFS0 is not backed by a file and cannot be memory mapped")
+		}
+		fileHandle, err := os.Open(p.Filepath)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		// The mapping outlives the handle used to create it
+		defer fileHandle.Close()
+		f = fileHandle
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	size, err := allocSize(uint64(fi.Size()))
+	if err != nil {
+		return err
+	}
+	var data []byte
+	unmap := func() error { return nil }
+	if size > 0 {
+		if data, unmap, err = mmapFile(f, size); err != nil {
+			return err
+		}
+	}
+
+	prev := p.closer
+	p.closer = closerFunc(func() error {
+		err := unmap()
+		if prev != nil {
+			if closeErr := prev.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	})
+	p.src = bytes.NewReader(data)
+	p.mapped = true
+	return nil
+}
+
+// closerFunc adapts a function to io.Closer
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package gopfs0
+
+import "os"
+
+func mmapFile(f *os.File, size int) ([]byte, func() error, error) {
+	return nil, nil, errMmapUnsupported
+}
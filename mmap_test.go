@@ -0,0 +1,66 @@
+package gopfs0
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// concurrentFiles returns files of distinct content for concurrent read tests
+func concurrentFiles(n int) map[string][]byte {
+	files := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		files[fmt.Sprintf("%02d.nca", i)] = bytes.Repeat([]byte{byte(i), byte(i * 7)}, 0x8000+i*0x100)
+	}
+	return files
+}
+
+func TestUseMmapConcurrentReads(t *testing.T) {
+	files := concurrentFiles(8)
+	p := NewPFS0(writeFixtureFile(t, files))
+	if err := p.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.UseMmap(); errors.Is(err, errMmapUnsupported) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// Several goroutines hash every file of the one mapping at once
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, f := range p.Files {
+				got, err := p.HashFile(uint16(i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if want := sha256.Sum256(files[f.Name]); got != want {
+					t.Errorf("HashFile(%d) of %s = %x, want %x", i, f.Name, got, want)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	dir := t.TempDir()
+	if err := p.ExtractAllParallel(dir, 4); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("extracted %s does not match, err %v", name, err)
+		}
+	}
+}
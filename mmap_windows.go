@@ -0,0 +1,33 @@
+//go:build windows
+
+package gopfs0
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mmapFile maps the first size bytes of f read-only
+func mmapFile(f *os.File, size int) ([]byte, func() error, error) {
+	mapping, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := syscall.MapViewOfFile(mapping, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(mapping)
+		return nil, nil, err
+	}
+	// The view is mapped by the OS outside the Go heap and stays valid until
+	// UnmapViewOfFile, so converting its address is safe
+	data := unsafe.Slice((*byte)(unsafe.Pointer(addr)), size)
+	unmap := func() error {
+		err := syscall.UnmapViewOfFile(addr)
+		if closeErr := syscall.CloseHandle(mapping); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	return data, unmap, nil
+}
@@ -0,0 +1,131 @@
+package gopfs0
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	nacpSize           = 0x4000
+	nacpTitleSize      = 0x300
+	nacpNameSize       = 0x200
+	nacpPublisherSize  = 0x100
+	nacpPresenceGroup  = 0x3038
+	nacpDisplayVersion = 0x3060
+)
+
+// Language is an index into NACP.Titles
+type Language int
+
+// Languages of the NACP title entries
+const (
+	LanguageAmericanEnglish Language = iota
+	LanguageBritishEnglish
+	LanguageJapanese
+	LanguageFrench
+	LanguageGerman
+	LanguageLatinAmericanSpanish
+	LanguageSpanish
+	LanguageItalian
+	LanguageDutch
+	LanguageCanadianFrench
+	LanguagePortuguese
+	LanguageRussian
+	LanguageKorean
+	LanguageTraditionalChinese
+	LanguageSimplifiedChinese
+	LanguageBrazilianPortuguese
+)
+
+func (l Language) String() string {
+	if l < 0 || int(l) >= len(NACPLanguages) {
+		return fmt.Sprintf("Language(%d)", int(l))
+	}
+	return NACPLanguages[l]
+}
+
+// NACPLanguages names the languages of NACP.Titles, in order
+var NACPLanguages = [16]string{
+	"AmericanEnglish", "BritishEnglish", "Japanese", "French", "German",
+	"LatinAmericanSpanish", "Spanish", "Italian", "Dutch", "CanadianFrench",
+	"Portuguese", "Russian", "Korean", "TraditionalChinese",
+	"SimplifiedChinese", "BrazilianPortuguese",
+}
+
+// NACPTitle is the name and publisher of a title in one language
+type NACPTitle struct {
+	Name      string
+	Publisher string
+}
+
+// NACP holds the fields of an application control property file
+type NACP struct {
+	// Titles is indexed like NACPLanguages. Languages the title does not
+	// support have empty entries.
+	Titles         [16]NACPTitle
+	DisplayVersion string
+	// ApplicationID is the presence group ID, which is the title ID of the
+	// base application
+	ApplicationID uint64
+}
+
+// Title returns the name and publisher for lang. If the title has no entry
+// for lang, the first language that has one is used instead. ok is false if
+// every entry is empty.
+func (n *NACP) Title(lang Language) (name, publisher string, ok bool) {
+	if lang >= 0 && int(lang) < len(n.Titles) && n.Titles[lang].Name != "" {
+		return n.Titles[lang].Name, n.Titles[lang].Publisher, true
+	}
+	for _, t := range n.Titles {
+		if t.Name != "" {
+			return t.Name, t.Publisher, true
+		}
+	}
+	return "", "", false
+}
+
+// ParseNACP decodes the control property file b
+func ParseNACP(b []byte) (*NACP, error) {
+	if len(b) < nacpSize {
+		return nil, fmt.Errorf("%w: NACP is 0x%X bytes, expected 0x%X", ErrTruncated, len(b), nacpSize)
+	}
+	n := &NACP{
+		DisplayVersion: nacpString(b[nacpDisplayVersion : nacpDisplayVersion+0x10]),
+		ApplicationID:  binary.LittleEndian.Uint64(b[nacpPresenceGroup:]),
+	}
+	for i := range n.Titles {
+		t := b[i*nacpTitleSize : (i+1)*nacpTitleSize]
+		n.Titles[i] = NACPTitle{
+			Name:      nacpString(t[:nacpNameSize]),
+			Publisher: nacpString(t[nacpNameSize : nacpNameSize+nacpPublisherSize]),
+		}
+	}
+	return n, nil
+}
+
+// nacpString returns the NUL terminated string at the start of b
+func nacpString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// ReadNACP reads and parses a .nacp stored directly in the PFS0. Most NSPs
+// only have one inside the RomFS of their control NCA, which can be read with
+// ReadRomFSFile(ind, "/control.nacp", keyset) and parsed with ParseNACP.
+func (p *PFS0) ReadNACP() (*NACP, error) {
+	for i, f := range p.Files {
+		if strings.HasSuffix(f.Name, ".nacp") {
+			b, err := p.readFile(uint16(i))
+			if err != nil {
+				return nil, err
+			}
+			return ParseNACP(b)
+		}
+	}
+	return nil, errors.New("No loose .nacp found in PFS0. It is usually in the RomFS of the control NCA; see ReadRomFSFile")
+}
@@ -0,0 +1,77 @@
+package gopfs0
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+// buildNACP returns a control property file with the given titles, display
+// version and presence group ID
+func buildNACP(titles map[Language]NACPTitle, version string, id uint64) []byte {
+	b := make([]byte, nacpSize)
+	for lang, title := range titles {
+		t := b[int(lang)*nacpTitleSize:]
+		copy(t[:nacpNameSize], title.Name)
+		copy(t[nacpNameSize:nacpNameSize+nacpPublisherSize], title.Publisher)
+	}
+	copy(b[nacpDisplayVersion:nacpDisplayVersion+0x10], version)
+	binary.LittleEndian.PutUint64(b[nacpPresenceGroup:], id)
+	return b
+}
+
+func TestNACPTitle(t *testing.T) {
+	japanese := NACPTitle{Name: "ゼルダの伝説", Publisher: "任天堂"}
+	french := NACPTitle{Name: "La Légende", Publisher: "Nintendo France"}
+	b := buildNACP(map[Language]NACPTitle{
+		LanguageJapanese: japanese,
+		LanguageFrench:   french,
+	}, "1.2.3", 0x0100000000010000)
+
+	// Read it through a PFS0 as well, as ReadNACP would find it
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(pfs0test.BuildFixture(map[string][]byte{"control.nacp": b})); err != nil {
+		t.Fatal(err)
+	}
+	n, err := p.ReadNACP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.DisplayVersion != "1.2.3" || n.ApplicationID != 0x0100000000010000 {
+		t.Fatalf("ReadNACP = version %q, ID 0x%016X", n.DisplayVersion, n.ApplicationID)
+	}
+
+	for _, tc := range []struct {
+		lang Language
+		want NACPTitle
+	}{
+		{LanguageJapanese, japanese},
+		{LanguageFrench, french},
+		// Languages without an entry fall back to the first that has one
+		{LanguageAmericanEnglish, japanese},
+		{LanguageBrazilianPortuguese, japanese},
+		{Language(-1), japanese},
+		{Language(len(NACPLanguages)), japanese},
+	} {
+		name, publisher, ok := n.Title(tc.lang)
+		if !ok || name != tc.want.Name || publisher != tc.want.Publisher {
+			t.Errorf("Title(%v) = %q, %q, %v, want %q, %q", tc.lang, name, publisher, ok, tc.want.Name, tc.want.Publisher)
+		}
+	}
+
+	empty, err := ParseNACP(buildNACP(nil, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, publisher, ok := empty.Title(LanguageAmericanEnglish); ok || name != "" || publisher != "" {
+		t.Errorf("Title of an empty NACP = %q, %q, %v", name, publisher, ok)
+	}
+}
+
+func TestParseNACPTruncated(t *testing.T) {
+	if _, err := ParseNACP(make([]byte, nacpSize-1)); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("ParseNACP error = %v, want ErrTruncated", err)
+	}
+}
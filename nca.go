@@ -0,0 +1,289 @@
+package gopfs0
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	ncaHeaderSize = 0xC00
+	ncaMediaUnit  = 0x200
+
+	// Offsets inside the NCA header, all relative to the start of the NCA
+	ncaMagicOffset  = 0x200
+	ncaContentType  = 0x205
+	ncaKeyGenOld    = 0x206
+	ncaKeyGenOffset = 0x220
+	ncaSectionTable = 0x240
+	ncaFsHeaders    = 0x400
+
+	// Values found in an NCA section's filesystem header
+	ncaFsTypePFS0     = 1
+	ncaEncryptionNone = 1
+)
+
+// NcaContentType identifies what an NCA holds
+type NcaContentType byte
+
+// NCA content types, as stored in the NCA header
+const (
+	NcaProgram NcaContentType = iota
+	NcaMeta
+	NcaControl
+	NcaManual
+	NcaData
+	NcaPublicData
+)
+
+func (t NcaContentType) String() string {
+	switch t {
+	case NcaProgram:
+		return "Program"
+	case NcaMeta:
+		return "Meta"
+	case NcaControl:
+		return "Control"
+	case NcaManual:
+		return "Manual"
+	case NcaData:
+		return "Data"
+	case NcaPublicData:
+		return "PublicData"
+	}
+	return fmt.Sprintf("NcaContentType(%d)", byte(t))
+}
+
+// ErrEncrypted is returned when content can only be read after decrypting it
+var ErrEncrypted = errors.New("Content is encrypted and must be decrypted first")
+
+// ncaPlaintext reports whether an NCA header has already been decrypted
+func ncaPlaintext(hdr []byte) bool {
+	if len(hdr) < ncaHeaderSize {
+		return false
+	}
+	switch string(hdr[ncaMagicOffset : ncaMagicOffset+4]) {
+	case "NCA3", "NCA2":
+		return true
+	}
+	return false
+}
+
+// decryptNcaHeader returns a plaintext copy of the 0xC00 byte NCA header raw.
+// A header that is already plaintext is returned as is and needs no keys.
+func decryptNcaHeader(raw []byte, keyset *Keyset) ([]byte, error) {
+	if ncaPlaintext(raw) {
+		return raw, nil
+	}
+	if len(raw) < ncaHeaderSize {
+		return nil, fmt.Errorf("%w: NCA header is only 0x%X bytes", ErrTruncated, len(raw))
+	}
+	key, err := keyset.requireKey("header_key", 0x20)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := make([]byte, ncaHeaderSize)
+	copy(hdr, raw)
+	if err := decryptXTS(key, hdr[:0x400], 0x200, 0); err != nil {
+		return nil, err
+	}
+	switch string(hdr[ncaMagicOffset : ncaMagicOffset+4]) {
+	case "NCA3":
+		err = decryptXTS(key, hdr[0x400:], 0x200, 2)
+	case "NCA2":
+		// NCA2 encrypts each section header on its own as sector 0
+		for i := ncaFsHeaders; i < ncaHeaderSize && err == nil; i += 0x200 {
+			err = decryptXTS(key, hdr[i:i+0x200], 0x200, 0)
+		}
+	default:
+		return nil, errors.New("Unable to decrypt NCA header. Check header_key is correct")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hdr, nil
+}
+
+// ncaHeader reads and decrypts the header of the NCA with the given index
+func (p *PFS0) ncaHeader(ind uint16, keyset *Keyset) ([]byte, error) {
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, err
+	}
+	if size < ncaHeaderSize {
+		return nil, fmt.Errorf("%w: %s is smaller than an NCA header", ErrTruncated, p.Files[ind].Name)
+	}
+	raw, err := p.readAt(offset, ncaHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := decryptNcaHeader(raw, keyset)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %w", p.Files[ind].Name, err)
+	}
+	return hdr, nil
+}
+
+// NCAHeader returns the first n bytes of the file with the given index as
+// stored, for handing to an external NCA parser. n defaults to the 0xC00 byte
+// NCA header when it is not positive and is clamped to the file's size. The
+// bytes are not decrypted.
+func (p *PFS0) NCAHeader(ind uint16, n int) ([]byte, error) {
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, err
+	}
+	want := uint64(ncaHeaderSize)
+	if n > 0 {
+		want = uint64(n)
+	}
+	if want > size {
+		want = size
+	}
+	return p.readAt(offset, want)
+}
+
+// HasContentType reports whether any NCA in the PFS0 has content type t.
+// keyset is only needed when NCA headers are encrypted; without the
+// header_key the error wraps ErrKeysRequired.
+func (p *PFS0) HasContentType(t NcaContentType, keyset *Keyset) (bool, error) {
+	for i, f := range p.Files {
+		if !strings.HasSuffix(f.Name, ".nca") {
+			continue
+		}
+		hdr, err := p.ncaHeader(uint16(i), keyset)
+		if err != nil {
+			return false, err
+		}
+		if NcaContentType(hdr[ncaContentType]) == t {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ncaSection returns the offset and size of section i relative to the start
+// of the NCA, along with its filesystem header. hdr must be plaintext.
+func ncaSection(hdr []byte, i int) (uint64, uint64, []byte) {
+	entry := hdr[ncaSectionTable+0x10*i:]
+	start := uint64(binary.LittleEndian.Uint32(entry[0:4])) * ncaMediaUnit
+	end := uint64(binary.LittleEndian.Uint32(entry[4:8])) * ncaMediaUnit
+	fsHeader := hdr[ncaFsHeaders+0x200*i : ncaFsHeaders+0x200*(i+1)]
+	if end < start {
+		end = start
+	}
+	return start, end - start, fsHeader
+}
+
+// ncaSectionPFS0 returns the offset and size of the PFS0 stored in section i
+// relative to the start of the NCA. It fails with ErrEncrypted unless both the
+// header and the section are plaintext.
+func ncaSectionPFS0(hdr []byte, i int) (uint64, uint64, error) {
+	if !ncaPlaintext(hdr) {
+		return 0, 0, fmt.Errorf("%w: NCA header is encrypted", ErrEncrypted)
+	}
+	start, size, fsHeader := ncaSection(hdr, i)
+	if fsHeader[0x2] != ncaFsTypePFS0 {
+		return 0, 0, fmt.Errorf("NCA section %d does not contain a PFS0", i)
+	}
+	if fsHeader[0x4] != ncaEncryptionNone {
+		return 0, 0, fmt.Errorf("%w: NCA section %d is encrypted", ErrEncrypted, i)
+	}
+	pfs0Offset := binary.LittleEndian.Uint64(fsHeader[0x40:0x48])
+	pfs0Size := binary.LittleEndian.Uint64(fsHeader[0x48:0x50])
+	if pfs0Offset > size || pfs0Size > size-pfs0Offset {
+		return 0, 0, fmt.Errorf("%w: PFS0 extends past end of NCA section %d", ErrTruncated, i)
+	}
+	return start + pfs0Offset, pfs0Size, nil
+}
+
+// ncaKeyGeneration returns the master key revision required by an NCA header.
+// It reports false when the header is still encrypted.
+func ncaKeyGeneration(hdr []byte) (byte, bool) {
+	if !ncaPlaintext(hdr) {
+		return 0, false
+	}
+	gen := hdr[ncaKeyGenOld]
+	if hdr[ncaKeyGenOffset] > gen {
+		gen = hdr[ncaKeyGenOffset]
+	}
+	// Generations 0 and 1 both use master_key_00
+	if gen > 0 {
+		gen--
+	}
+	return gen, true
+}
+
+// RequiredKeyGeneration returns the highest master key revision (the XX in
+// master_key_XX) needed to decrypt the content of the PFS0.
+// Plaintext NCA headers are read directly. Encrypted headers cannot be read
+// without keys, so the master key revision recorded in each ticket is used
+// for them instead.
+func (p *PFS0) RequiredKeyGeneration() (byte, error) {
+	var gen byte
+	found := false
+	for i, f := range p.Files {
+		var n uint64
+		switch {
+		case strings.HasSuffix(f.Name, ".nca"):
+			n = ncaHeaderSize
+		case strings.HasSuffix(f.Name, ".tik"):
+			n = 0x400
+		default:
+			continue
+		}
+		offset, size, err := p.fileRegion(uint16(i))
+		if err != nil {
+			return 0, err
+		}
+		buf, err := p.readAt(offset, min(size, n))
+		if err != nil {
+			return 0, err
+		}
+
+		var g byte
+		ok := false
+		if strings.HasSuffix(f.Name, ".nca") {
+			g, ok = ncaKeyGeneration(buf)
+		} else if body, err := ticketBody(buf); err == nil {
+			g, ok = body[tikMasterKeyRevision], true
+		}
+		if ok {
+			found = true
+			if g > gen {
+				gen = g
+			}
+		}
+	}
+	if !found {
+		return 0, errors.New("Unable to determine key generation. NCA headers are encrypted and no ticket is present")
+	}
+	return gen, nil
+}
+
+// VerifyNcaNames checks that each NCA is named after its content ID and
+// returns the names of those that are not. The content ID is the first 16
+// bytes of the SHA-256 of the whole NCA, which is also what the CNMT records,
+// so every NCA is hashed in full. The name is compared up to its first dot,
+// ignoring case. No keys are needed, as the hash covers the stored, encrypted
+// bytes.
+func (p *PFS0) VerifyNcaNames() ([]string, error) {
+	var mismatched []string
+	for i, f := range p.Files {
+		if !strings.HasSuffix(f.Name, ".nca") {
+			continue
+		}
+		digest, err := p.HashFile(uint16(i))
+		if err != nil {
+			return mismatched, err
+		}
+		contentID := strings.Split(f.Name, ".")[0]
+		if !strings.EqualFold(contentID, hex.EncodeToString(digest[:16])) {
+			mismatched = append(mismatched, f.Name)
+		}
+	}
+	return mismatched, nil
+}
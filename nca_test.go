@@ -0,0 +1,45 @@
+package gopfs0
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+func TestRequiredKeyGenerationChecksBounds(t *testing.T) {
+	b := pfs0test.BuildFixture(map[string][]byte{"0.nca": make([]byte, 0x10)})
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(b); err != nil {
+		t.Fatal(err)
+	}
+	// An NCA reaching past the end of the file must not be read
+	p.Files[0].Size = ncaHeaderSize
+	if _, err := p.RequiredKeyGeneration(); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("RequiredKeyGeneration error = %v, want ErrTruncated", err)
+	}
+}
+
+func TestVerifyNcaNames(t *testing.T) {
+	content := []byte("nca content")
+	digest := sha256.Sum256(content)
+	good := strings.ToUpper(hex.EncodeToString(digest[:16])) + ".nca"
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(pfs0test.BuildFixture(map[string][]byte{
+		good:                   content,
+		"renamed.cnmt.nca":     content,
+		"0123456789abcdef.tik": []byte("not an NCA"),
+	})); err != nil {
+		t.Fatal(err)
+	}
+	mismatched, err := p.VerifyNcaNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatched) != 1 || mismatched[0] != "renamed.cnmt.nca" {
+		t.Fatalf("VerifyNcaNames = %q, want [renamed.cnmt.nca]", mismatched)
+	}
+}
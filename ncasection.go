@@ -0,0 +1,220 @@
+package gopfs0
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ncaKeyAreaIndex = 0x207
+	ncaRightsID     = 0x230
+	ncaKeyArea      = 0x300
+
+	// Section types and encryption found in the section's filesystem header
+	ncaFsTypeRomFS   = 0
+	ncaEncryptionCTR = 3
+)
+
+// ncaKeyAreaNames are the key_area_key kinds selected by ncaKeyAreaIndex
+var ncaKeyAreaNames = []string{"application", "ocean", "system"}
+
+// ncaSectionData is a readable, decrypted view of one NCA section
+type ncaSectionData struct {
+	io.ReaderAt
+	size     uint64
+	fsHeader []byte
+}
+
+// openNcaSection returns a decrypted view of section i of the NCA with the
+// given index. Sections are decrypted with the key area key named by the
+// header, or with the title key from the matching ticket when the NCA has a
+// rights ID.
+func (p *PFS0) openNcaSection(ind uint16, i int, keyset *Keyset) (*ncaSectionData, error) {
+	if i < 0 || i > 3 {
+		return nil, fmt.Errorf("NCA section %d out of range", i)
+	}
+	hdr, err := p.ncaHeader(ind, keyset)
+	if err != nil {
+		return nil, err
+	}
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, err
+	}
+	start, sectionSize, fsHeader := ncaSection(hdr, i)
+	if sectionSize == 0 {
+		return nil, fmt.Errorf("NCA section %d of %s is empty", i, p.Files[ind].Name)
+	}
+	if start+sectionSize > size {
+		return nil, fmt.Errorf("%w: section %d extends past end of %s", ErrTruncated, i, p.Files[ind].Name)
+	}
+
+	nca := io.NewSectionReader(p.readerAt(), int64(offset), int64(size))
+	section := &ncaSectionData{size: sectionSize, fsHeader: fsHeader}
+	switch fsHeader[0x4] {
+	case ncaEncryptionNone:
+		section.ReaderAt = io.NewSectionReader(nca, int64(start), int64(sectionSize))
+	case ncaEncryptionCTR:
+		key, err := p.ncaSectionKey(hdr, keyset)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decrypt %s: %w", p.Files[ind].Name, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		ctr := &ctrReaderAt{r: nca, block: block, start: int64(start), size: int64(sectionSize)}
+		// The upper half of the counter is stored little-endian
+		for j := 0; j < 8; j++ {
+			ctr.counter[j] = fsHeader[0x147-j]
+		}
+		section.ReaderAt = ctr
+	default:
+		return nil, fmt.Errorf("Unsupported encryption type %d for section %d of %s", fsHeader[0x4], i, p.Files[ind].Name)
+	}
+	return section, nil
+}
+
+// WriteNcaSection decrypts section (0 to 3) of the NCA with the given index
+// and copies it to w. Without the keys the section needs, the error wraps
+// ErrKeysRequired.
+func (p *PFS0) WriteNcaSection(ind uint16, section int, keyset *Keyset, w io.Writer) error {
+	data, err := p.openNcaSection(ind, section, keyset)
+	if err != nil {
+		return err
+	}
+	buf, release := p.copyBuffer()
+	defer release()
+	n, err := io.CopyBuffer(w, io.NewSectionReader(data, 0, int64(data.size)), buf)
+	if err == nil && uint64(n) != data.size {
+		err = fmt.Errorf("%w: expected 0x%X bytes of section %d of %s, copied 0x%X", ErrTruncated, data.size, section, p.Files[ind].Name, n)
+	}
+	return err
+}
+
+// ncaSectionKey returns the AES-CTR key for the sections of the NCA with the
+// plaintext header hdr
+func (p *PFS0) ncaSectionKey(hdr []byte, keyset *Keyset) ([]byte, error) {
+	gen, _ := ncaKeyGeneration(hdr)
+	var rightsID [16]byte
+	copy(rightsID[:], hdr[ncaRightsID:ncaRightsID+16])
+	if rightsID != [16]byte{} {
+		titleKey, err := p.titleKey(rightsID, gen, keyset)
+		if err != nil {
+			return nil, err
+		}
+		return titleKey[:], nil
+	}
+
+	kind := int(hdr[ncaKeyAreaIndex])
+	if kind >= len(ncaKeyAreaNames) {
+		return nil, fmt.Errorf("Unknown key area key index %d", kind)
+	}
+	kek, err := keyset.requireKey(fmt.Sprintf("key_area_key_%s_%02x", ncaKeyAreaNames[kind], gen), 0x10)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	// The key area holds four keys; the third is used for AES-CTR sections
+	key := make([]byte, 0x10)
+	block.Decrypt(key, hdr[ncaKeyArea+0x20:ncaKeyArea+0x30])
+	return key, nil
+}
+
+// titleKey finds the ticket for rightsID and decrypts its title key with
+// title_kek_XX, XX being gen
+func (p *PFS0) titleKey(rightsID [16]byte, gen byte, keyset *Keyset) ([16]byte, error) {
+	var titleKey [16]byte
+	for i, f := range p.Files {
+		if !strings.HasSuffix(f.Name, ".tik") {
+			continue
+		}
+		tik, err := p.readFile(uint16(i))
+		if err != nil {
+			return titleKey, err
+		}
+		body, err := ticketBody(tik)
+		if err != nil {
+			return titleKey, err
+		}
+		if !bytes.Equal(body[tikRightsID:tikRightsID+16], rightsID[:]) {
+			continue
+		}
+		return decryptTitleKey(body, gen, keyset)
+	}
+	return titleKey, fmt.Errorf("%w: no ticket for rights ID %X", ErrKeysRequired, rightsID)
+}
+
+// decryptTitleKey decrypts the title key of the ticket body with
+// title_kek_XX, XX being gen
+func decryptTitleKey(body []byte, gen byte, keyset *Keyset) ([16]byte, error) {
+	var titleKey [16]byte
+	if body[tikTitleKeyType] != 0 {
+		return titleKey, errors.New("Personalized tickets are not supported")
+	}
+	kek, err := keyset.requireKey(fmt.Sprintf("title_kek_%02x", gen), 0x10)
+	if err != nil {
+		return titleKey, err
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return titleKey, err
+	}
+	block.Decrypt(titleKey[:], body[tikTitleKeyBlock:tikTitleKeyBlock+16])
+	return titleKey, nil
+}
+
+// ctrReaderAt decrypts an AES-CTR encrypted NCA section as it is read.
+// Offsets passed to ReadAt are relative to the section, while the counter is
+// based on the offset within the NCA.
+type ctrReaderAt struct {
+	// r reads the whole NCA
+	r       io.ReaderAt
+	block   cipher.Block
+	counter [8]byte
+	start   int64
+	size    int64
+}
+
+func (c *ctrReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("Negative offset")
+	}
+	if off >= c.size {
+		return 0, io.EOF
+	}
+	want := len(b)
+	if int64(want) > c.size-off {
+		want = int(c.size - off)
+	}
+
+	// Decrypt from the start of the AES block containing off
+	pos := c.start + off
+	aligned := pos &^ (aes.BlockSize - 1)
+	skip := int(pos - aligned)
+	buf := make([]byte, skip+want)
+	n, err := c.r.ReadAt(buf, aligned)
+	if n < skip {
+		return 0, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, c.counter[:])
+	binary.BigEndian.PutUint64(iv[8:], uint64(aligned)/aes.BlockSize)
+	cipher.NewCTR(c.block, iv).XORKeyStream(buf[:n], buf[:n])
+
+	copied := copy(b, buf[skip:n])
+	if copied < len(b) && err == nil {
+		err = io.EOF
+	}
+	return copied, err
+}
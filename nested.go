@@ -0,0 +1,62 @@
+package gopfs0
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrEncryptedSection is returned by OpenNested when the data does not start
+// with a PFS0 or HFS0 magic, which almost always means it is still encrypted.
+// It wraps ErrEncrypted.
+var ErrEncryptedSection = fmt.Errorf("%w: section does not start with a PFS0 or HFS0 magic", ErrEncrypted)
+
+// pathReaderAt reads from the file at its path, opening it for every call
+type pathReaderAt string
+
+func (filepath pathReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	fileHandle, err := os.Open(string(filepath))
+	if err != nil {
+		return 0, err
+	}
+	defer fileHandle.Close()
+	return fileHandle.ReadAt(b, off)
+}
+
+// readerAt returns a reader for the underlying NSP that stays usable without
+// being closed, for handing to PFS0s nested inside this one
+func (p *PFS0) readerAt() io.ReaderAt {
+	if p.src != nil {
+		return p.src
+	}
+	return pathReaderAt(p.Filepath)
+}
+
+// OpenNested parses the file with the given index as a PFS0 of its own, such
+// as the PFS0 stored inside an unencrypted NCA section. If the file does not
+// start with a known magic ErrEncryptedSection is returned rather than a
+// parse error.
+func (p *PFS0) OpenNested(ind uint16) (*PFS0, error) {
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, err
+	}
+	return p.nestedAt(p.Files[ind].Name, offset, size)
+}
+
+// nestedAt parses the size bytes at offset of the underlying NSP as a PFS0
+func (p *PFS0) nestedAt(name string, offset, size uint64) (*PFS0, error) {
+	section := io.NewSectionReader(p.readerAt(), int64(offset), int64(size))
+	magicBytes := make([]byte, 4)
+	if _, err := section.ReadAt(magicBytes, 0); err != nil {
+		return nil, fmt.Errorf("%w: unable to read magic of %s: %v", ErrTruncated, name, err)
+	}
+	if _, ok := lookupFormat(string(magicBytes)); !ok {
+		return nil, p.invalid(fmt.Errorf("%w: %s", ErrEncryptedSection, name))
+	}
+	n := &PFS0{Filepath: name, Basename: basename(name), Size: size, OnEvent: p.OnEvent, src: section}
+	if err := n.parseMetadata(section); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
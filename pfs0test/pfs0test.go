@@ -0,0 +1,34 @@
+// Package pfs0test builds small PFS0 archives for tests of code that uses
+// gopfs0
+package pfs0test
+
+import (
+	"sort"
+
+	"github.com/nosmokingbandit/gopfs0/internal/pfs0fmt"
+)
+
+// BuildFixture returns a valid PFS0 holding files, keyed by name. Files are
+// stored in name order so the same map always gives the same bytes. The
+// header is written by the same code as gopfs0's Repack. Wrap the result in a
+// bytes.Reader to use it as an io.ReaderAt.
+func BuildFixture(files map[string][]byte) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]pfs0fmt.Entry, len(names))
+	var offset uint64
+	for i, name := range names {
+		size := uint64(len(files[name]))
+		entries[i] = pfs0fmt.Entry{Name: name, Offset: offset, Size: size}
+		offset += size
+	}
+	out := pfs0fmt.BuildHeader(entries)
+	for _, name := range names {
+		out = append(out, files[name]...)
+	}
+	return out
+}
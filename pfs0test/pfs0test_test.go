@@ -0,0 +1,87 @@
+package pfs0test_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0"
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+func TestBuildFixtureRoundTrip(t *testing.T) {
+	for _, files := range []map[string][]byte{
+		{},
+		{"a.nca": []byte("abc")},
+		{
+			"0100000000010000.tik":  bytes.Repeat([]byte{0x11}, 0x2C0),
+			"0100000000010000.cert": bytes.Repeat([]byte{0x22}, 0x700),
+			"program.nca":           bytes.Repeat([]byte{0x33}, 0x1001),
+			"empty.nca":             {},
+		},
+	} {
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		b := pfs0test.BuildFixture(files)
+		if !bytes.Equal(b, pfs0test.BuildFixture(files)) {
+			t.Fatalf("BuildFixture(%v) is not deterministic", names)
+		}
+
+		fileCount, stringTableSize, headerLen, err := gopfs0.ValidateHeader(b)
+		if err != nil {
+			t.Fatalf("ValidateHeader(%v): %v", names, err)
+		}
+		if int(fileCount) != len(files) || headerLen != gopfs0.HeaderBaseSize+gopfs0.EntrySize*uint32(len(files)) {
+			t.Fatalf("ValidateHeader(%v) = %d files, header 0x%X", names, fileCount, headerLen)
+		}
+		if (headerLen+stringTableSize)%gopfs0.Pfs0HeaderSize != 0 {
+			t.Errorf("content of %v starts at 0x%X, not aligned to 0x%X", names, headerLen+stringTableSize, gopfs0.Pfs0HeaderSize)
+		}
+
+		p := &gopfs0.PFS0{}
+		if err := p.ReadMetadataFromBytes(b); err != nil {
+			t.Fatalf("ReadMetadataFromBytes(%v): %v", names, err)
+		}
+		if len(p.Files) != len(names) {
+			t.Fatalf("parsed %d files, want %d", len(p.Files), len(names))
+		}
+		for i, f := range p.Files {
+			if f.Name != names[i] || f.Size != uint64(len(files[names[i]])) {
+				t.Errorf("file %d = %q of 0x%X bytes, want %q of 0x%X bytes", i, f.Name, f.Size, names[i], len(files[names[i]]))
+			}
+			var buf bytes.Buffer
+			if _, err := p.WriteFileTo(uint16(i), &buf); err != nil {
+				t.Fatalf("WriteFileTo(%d): %v", i, err)
+			}
+			if !bytes.Equal(buf.Bytes(), files[f.Name]) {
+				t.Errorf("content of %s does not match", f.Name)
+			}
+		}
+		if err := p.Validate(); err != nil {
+			t.Errorf("Validate(%v): %v", names, err)
+		}
+	}
+}
+
+func TestBuildFixtureMatchesRepack(t *testing.T) {
+	b := pfs0test.BuildFixture(map[string][]byte{
+		"0100000000010000.tik": bytes.Repeat([]byte{0x11}, 0x2C0),
+		"program.nca":          bytes.Repeat([]byte{0x33}, 0x1001),
+		"empty.nca":            {},
+	})
+	p := &gopfs0.PFS0{}
+	if err := p.ReadMetadataFromBytes(b); err != nil {
+		t.Fatal(err)
+	}
+	var repacked bytes.Buffer
+	if err := p.Repack(&repacked, gopfs0.RepackOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(repacked.Bytes(), b) {
+		t.Fatalf("Repack of a fixture wrote 0x%X bytes that differ from the 0x%X byte fixture", repacked.Len(), len(b))
+	}
+}
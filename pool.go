@@ -0,0 +1,129 @@
+package gopfs0
+
+import (
+	"io"
+	"sync"
+)
+
+// BufferPool supplies the copy buffers used when files are read front to
+// back. A buffer is taken with Get when a read starts, such as WriteFileTo,
+// an extraction or the goroutine behind NcaReader, and handed back with Put
+// once that read has finished with it. Nothing holds on to a buffer after
+// Put, so it may be given straight to another read. Chunks sent by NcaReader
+// and StreamReader are allocated separately and belong to the receiver.
+// Implementations must be safe for concurrent use.
+type BufferPool interface {
+	// Get returns a buffer to copy through. Its length is the read size and
+	// must not be zero.
+	Get() []byte
+	// Put returns a buffer obtained from Get
+	Put([]byte)
+}
+
+// syncBufferPool is a BufferPool of equally sized buffers backed by a
+// sync.Pool
+type syncBufferPool struct {
+	pool sync.Pool
+	size int
+}
+
+// NewBufferPool returns a BufferPool of size byte buffers that can be shared
+// by every PFS0 in a program
+func NewBufferPool(size int) BufferPool {
+	if size <= 0 {
+		size = defaultSequentialBufferSize
+	}
+	return &syncBufferPool{size: size}
+}
+
+func (s *syncBufferPool) Get() []byte {
+	if b, ok := s.pool.Get().(*[]byte); ok {
+		return *b
+	}
+	return make([]byte, s.size)
+}
+
+func (s *syncBufferPool) Put(b []byte) {
+	// Buffers of other sizes did not come from this pool
+	if cap(b) < s.size {
+		return
+	}
+	b = b[:s.size]
+	s.pool.Put(&b)
+}
+
+// defaultBufferPool is used when neither BufferPool nor SequentialBufferSize
+// is set
+var defaultBufferPool = NewBufferPool(defaultSequentialBufferSize)
+
+// copyBuffer returns a buffer for a sequential read and the function that
+// releases it
+func (p *PFS0) copyBuffer() ([]byte, func()) {
+	pool := p.BufferPool
+	if pool == nil {
+		if p.SequentialBufferSize > 0 {
+			return make([]byte, p.SequentialBufferSize), func() {}
+		}
+		pool = defaultBufferPool
+	}
+	buf := pool.Get()
+	if len(buf) == 0 {
+		return make([]byte, defaultSequentialBufferSize), func() {}
+	}
+	return buf, func() { pool.Put(buf) }
+}
+
+// pooledReader buffers reads from src through a buffer from a BufferPool
+type pooledReader struct {
+	src  io.Reader
+	buf  []byte
+	r, w int
+	err  error
+}
+
+func (b *pooledReader) Read(p []byte) (int, error) {
+	if b.r == b.w {
+		if b.err != nil {
+			return 0, b.err
+		}
+		// Large reads gain nothing from going through the buffer
+		if len(p) >= len(b.buf) {
+			return b.src.Read(p)
+		}
+		b.r = 0
+		b.w, b.err = b.src.Read(b.buf)
+		if b.w == 0 {
+			return 0, b.err
+		}
+	}
+	n := copy(p, b.buf[b.r:b.w])
+	b.r += n
+	return n, nil
+}
+
+// WriteTo copies the rest of src to w through the pooled buffer, so io.Copy
+// does not allocate a buffer of its own
+func (b *pooledReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		if b.r < b.w {
+			n, err := w.Write(b.buf[b.r:b.w])
+			total += int64(n)
+			b.r += n
+			if err != nil {
+				return total, err
+			}
+			if b.r < b.w {
+				return total, io.ErrShortWrite
+			}
+		}
+		if b.err != nil {
+			if b.err == io.EOF {
+				return total, nil
+			}
+			return total, b.err
+		}
+		b.r = 0
+		b.w, b.err = b.src.Read(b.buf)
+	}
+}
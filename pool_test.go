@@ -0,0 +1,105 @@
+package gopfs0
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// countingPool is a BufferPool that counts the buffers taken and returned and
+// fails the test if one is returned that it did not hand out
+type countingPool struct {
+	t    *testing.T
+	size int
+
+	mu   sync.Mutex
+	free [][]byte
+	out  map[*byte]bool
+	gets int
+	puts int
+}
+
+func newCountingPool(t *testing.T, size int) *countingPool {
+	return &countingPool{t: t, size: size, out: make(map[*byte]bool)}
+}
+
+func (c *countingPool) Get() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var b []byte
+	if n := len(c.free); n > 0 {
+		b, c.free = c.free[n-1], c.free[:n-1]
+	} else {
+		b = make([]byte, c.size)
+	}
+	c.out[&b[0]] = true
+	c.gets++
+	return b
+}
+
+func (c *countingPool) Put(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.out[&b[0]] {
+		c.t.Errorf("buffer %p returned but not handed out", &b[0])
+	}
+	delete(c.out, &b[0])
+	c.free = append(c.free, b)
+	c.puts++
+}
+
+func TestExtractAllReusesOneBuffer(t *testing.T) {
+	files := concurrentFiles(8)
+	p := NewPFS0(writeFixtureFile(t, files))
+	if err := p.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	pool := newCountingPool(t, 0x1000)
+	p.BufferPool = pool
+
+	dir := t.TempDir()
+	if err := p.ExtractAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	// BenchmarkExtractAll shows what this saves in allocations
+	if pool.gets != 1 || pool.puts != 1 {
+		t.Fatalf("ExtractAll of %d files took %d buffers and returned %d, want 1", len(files), pool.gets, pool.puts)
+	}
+	checkExtracted(t, dir, files)
+}
+
+func TestExtractAllParallelBufferPerWorker(t *testing.T) {
+	const workers = 3
+	files := concurrentFiles(8)
+	p := NewPFS0(writeFixtureFile(t, files))
+	if err := p.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	pool := newCountingPool(t, 0x1000)
+	p.BufferPool = pool
+
+	dir := t.TempDir()
+	if err := p.ExtractAllParallel(dir, workers); err != nil {
+		t.Fatal(err)
+	}
+	if pool.gets != workers || pool.puts != workers {
+		t.Fatalf("%d workers took %d buffers and returned %d", workers, pool.gets, pool.puts)
+	}
+	if len(pool.out) != 0 {
+		t.Fatalf("%d buffers not returned", len(pool.out))
+	}
+	checkExtracted(t, dir, files)
+}
+
+// checkExtracted compares the files extracted to dir with files
+func checkExtracted(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("extracted %s does not match, err %v", name, err)
+		}
+	}
+}
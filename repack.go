@@ -0,0 +1,305 @@
+package gopfs0
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nosmokingbandit/gopfs0/internal/pfs0fmt"
+)
+
+// RepackOptions controls how Repack, RewriteNames and ReplaceFile write the
+// new archive
+type RepackOptions struct {
+	// PreserveHeader copies the original header, entry table and string table
+	// verbatim when no name, size or offset changes, keeping reserved fields
+	// and padding so the output matches the original byte for byte. When the
+	// header has to change it is regenerated as usual.
+	PreserveHeader bool
+
+	// Rename, when set, is called with the name of every entry and the result
+	// is stored in its place, after any renames passed to RewriteNames. The
+	// new names must be non-empty and unique.
+	Rename func(old string) string
+
+	// PreserveReserved writes each file's parsed Reserved field into its new
+	// entry, as PreserveHeader also does. By default the reserved field is
+	// zero.
+	PreserveReserved bool
+	// Reserved sets the reserved field of the entries for the files whose
+	// original indices are its keys, taking precedence over PreserveReserved
+	Reserved map[uint16]uint32
+
+	// Alignment, when above 1, pads the data region so every file starts at
+	// a multiple of it, as ComputeLayout plans. By default files are stored
+	// back to back. It has no effect when PreserveHeader keeps the original
+	// layout.
+	Alignment int
+}
+
+// repackEntry is one file of an archive being written
+type repackEntry struct {
+	// index is the source file, or -1 for new content
+	index int
+	name  string
+	size  uint64
+	// reserved is written to the entry's reserved field
+	reserved uint32
+	// content replaces the source file when set, and must hold size bytes
+	content io.Reader
+}
+
+// RawHeader returns the header, entry table and string table exactly as they
+// are stored
+func (p *PFS0) RawHeader() ([]byte, error) {
+	return p.readAt(p.BaseOffset, uint64(p.HeaderLen)+uint64(p.StringTableSize))
+}
+
+// Repack writes the PFS0 to w with a freshly generated header and the files
+// stored back to back in index order, dropping any padding or trailing data
+func (p *PFS0) Repack(w io.Writer, opts RepackOptions) error {
+	entries, err := p.repackEntries()
+	if err != nil {
+		return err
+	}
+	return p.writeRepack(w, entries, opts)
+}
+
+// RewriteNames writes the PFS0 to w with the files whose indices are keys of
+// names renamed to the mapped value. Content is copied unchanged.
+func (p *PFS0) RewriteNames(w io.Writer, names map[uint16]string, opts RepackOptions) error {
+	entries, err := p.repackEntries()
+	if err != nil {
+		return err
+	}
+	for ind, name := range names {
+		if int(ind) >= len(entries) {
+			return fmt.Errorf("File index %d out of range", ind)
+		}
+		entries[ind].name = name
+	}
+	return p.writeRepack(w, entries, opts)
+}
+
+// ReplaceFile writes the PFS0 to w with the content of the file with the
+// given index replaced by the size bytes read from r
+func (p *PFS0) ReplaceFile(w io.Writer, ind uint16, r io.Reader, size uint64, opts RepackOptions) error {
+	entries, err := p.repackEntries()
+	if err != nil {
+		return err
+	}
+	if int(ind) >= len(entries) {
+		return fmt.Errorf("File index %d out of range", ind)
+	}
+	entries[ind].size = size
+	entries[ind].content = r
+	return p.writeRepack(w, entries, opts)
+}
+
+// StripTicket writes the PFS0 to w without its .tik and .cert files, with a
+// regenerated header and the remaining files stored back to back
+func (p *PFS0) StripTicket(w io.Writer) error {
+	entries, err := p.repackEntries()
+	if err != nil {
+		return err
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if !strings.HasSuffix(e.name, ".tik") && !strings.HasSuffix(e.name, ".cert") {
+			kept = append(kept, e)
+		}
+	}
+	return p.writeRepack(w, kept, RepackOptions{})
+}
+
+// repackEntries describes every file of the PFS0 as it stands
+func (p *PFS0) repackEntries() ([]repackEntry, error) {
+	if p.format() == FormatHFS0 {
+		return nil, errors.New("Repacking is only supported for PFS0")
+	}
+	entries := make([]repackEntry, len(p.Files))
+	for i, f := range p.Files {
+		entries[i] = repackEntry{index: i, name: f.Name, size: f.Size}
+	}
+	return entries, nil
+}
+
+// writeRepack writes a PFS0 holding entries to w
+func (p *PFS0) writeRepack(w io.Writer, entries []repackEntry, opts RepackOptions) error {
+	if opts.Rename != nil {
+		if err := renameEntries(entries, opts.Rename); err != nil {
+			return err
+		}
+	}
+	for i := range entries {
+		e := &entries[i]
+		if e.index < 0 {
+			continue
+		}
+		if reserved, ok := opts.Reserved[uint16(e.index)]; ok {
+			e.reserved = reserved
+		} else if opts.PreserveReserved || opts.PreserveHeader {
+			e.reserved = p.Files[e.index].Reserved
+		}
+	}
+	if opts.PreserveHeader && p.headerUnchanged(entries) {
+		return p.writePreserved(w, entries)
+	}
+
+	header, layout := buildHeader(entries, opts.Alignment)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	var pos uint64
+	for i, e := range entries {
+		if pad := layout[i].StartOffset - pos; pad > 0 {
+			if _, err := w.Write(make([]byte, pad)); err != nil {
+				return err
+			}
+		}
+		if err := p.writeEntryContent(w, e); err != nil {
+			return err
+		}
+		pos = layout[i].StartOffset + e.size
+	}
+	return nil
+}
+
+// renameEntries applies rename to the name of every entry, checking the
+// results are non-empty and unique
+func renameEntries(entries []repackEntry, rename func(string) string) error {
+	seen := make(map[string]string, len(entries))
+	for i := range entries {
+		old := entries[i].name
+		name := rename(old)
+		if name == "" {
+			return fmt.Errorf("Rename of %s produced an empty name", old)
+		}
+		if prev, ok := seen[name]; ok {
+			return fmt.Errorf("Rename of %s and %s both produced %s", prev, old, name)
+		}
+		seen[name] = old
+		entries[i].name = name
+	}
+	return nil
+}
+
+// headerUnchanged reports whether entries would produce the same entry table
+// as the original PFS0
+func (p *PFS0) headerUnchanged(entries []repackEntry) bool {
+	if len(entries) != len(p.Files) {
+		return false
+	}
+	for i, e := range entries {
+		if e.index != i || e.name != p.Files[i].Name || e.size != p.Files[i].Size || e.reserved != p.Files[i].Reserved {
+			return false
+		}
+	}
+	return true
+}
+
+// writePreserved writes the original header followed by the data region with
+// the original layout, including any padding between files. entries must
+// satisfy headerUnchanged.
+func (p *PFS0) writePreserved(w io.Writer, entries []repackEntry) error {
+	raw, err := p.RawHeader()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
+	pos := p.contentBase()
+	for _, i := range p.indicesByOffset() {
+		offset, _, err := p.fileRegion(i)
+		if err != nil {
+			return err
+		}
+		if offset < pos {
+			return errors.New("Cannot preserve the header of a PFS0 with overlapping files")
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(fileHandle, int64(pos), int64(offset-pos))); err != nil {
+			return err
+		}
+		if err := p.writeEntryContent(w, entries[i]); err != nil {
+			return err
+		}
+		pos = offset + entries[i].size
+	}
+	return nil
+}
+
+// writeEntryContent copies the content of e to w, checking its size
+func (p *PFS0) writeEntryContent(w io.Writer, e repackEntry) error {
+	var n int64
+	var err error
+	if e.content != nil {
+		n, err = io.CopyN(w, e.content, int64(e.size))
+	} else {
+		n, err = p.WriteFileTo(uint16(e.index), w)
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if uint64(n) != e.size {
+		return fmt.Errorf("%w: expected 0x%X bytes for %s, got 0x%X", ErrTruncated, e.size, e.name, n)
+	}
+	return nil
+}
+
+// ComputeLayout returns copies of files with the StartOffset each would have
+// in a repack storing them in the order given, with every file starting at a
+// multiple of alignment. An alignment of 0 or 1 stores them back to back, as
+// the repack writers do by default. ComputeHeaderLen gives the size of the
+// header the offsets are relative to.
+func ComputeLayout(files []File, alignment int) []File {
+	layout := make([]File, len(files))
+	var offset uint64
+	for i, f := range files {
+		if alignment > 1 {
+			if rem := offset % uint64(alignment); rem != 0 {
+				offset += uint64(alignment) - rem
+			}
+		}
+		layout[i] = f
+		layout[i].StartOffset = offset
+		offset += f.Size
+	}
+	return layout
+}
+
+// ComputeHeaderLen returns the size of the header, entry table and padded
+// string table a repack writes for files, which is where the data region
+// laid out by ComputeLayout starts
+func ComputeHeaderLen(files []File) uint64 {
+	entries := make([]repackEntry, len(files))
+	for i, f := range files {
+		entries[i] = repackEntry{name: f.Name, size: f.Size}
+	}
+	header, _ := buildHeader(entries, 0)
+	return uint64(len(header))
+}
+
+// buildHeader returns the header, entry table and string table for entries
+// laid out by ComputeLayout, along with that layout
+func buildHeader(entries []repackEntry, alignment int) ([]byte, []File) {
+	files := make([]File, len(entries))
+	for i, e := range entries {
+		files[i] = File{Size: e.size, Name: e.name, Reserved: e.reserved}
+	}
+	layout := ComputeLayout(files, alignment)
+
+	headerEntries := make([]pfs0fmt.Entry, len(layout))
+	for i, f := range layout {
+		headerEntries[i] = pfs0fmt.Entry{Name: f.Name, Offset: f.StartOffset, Size: f.Size, Reserved: f.Reserved}
+	}
+	return pfs0fmt.BuildHeader(headerEntries), layout
+}
@@ -0,0 +1,188 @@
+package gopfs0
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+const (
+	// Offset in the section's filesystem header of IVFC level 6, which holds
+	// the RomFS itself
+	ivfcLevel6Offset = 0x90
+	ivfcLevel6Size   = 0x98
+
+	romfsHeaderSize = 0x50
+	romfsDirEntry   = 0x18
+	romfsFileEntry  = 0x20
+	romfsNone       = 0xFFFFFFFF
+)
+
+// romfsHeader holds the table offsets of a RomFS, relative to its start
+type romfsHeader struct {
+	dirMetaOffset  uint64
+	dirMetaSize    uint64
+	fileMetaOffset uint64
+	fileMetaSize   uint64
+	dataOffset     uint64
+}
+
+// ReadRomFSFile returns the contents of the file at romfsPath inside the
+// RomFS section of the NCA with the given index. Paths are separated by "/"
+// and a leading "/" is optional. A missing path returns an error wrapping
+// fs.ErrNotExist.
+func (p *PFS0) ReadRomFSFile(ncaInd uint16, romfsPath string, keyset *Keyset) ([]byte, error) {
+	hdr, err := p.ncaHeader(ncaInd, keyset)
+	if err != nil {
+		return nil, err
+	}
+	sectionInd := -1
+	for i := 0; i < 4; i++ {
+		_, size, fsHeader := ncaSection(hdr, i)
+		if size > 0 && fsHeader[0x2] == ncaFsTypeRomFS {
+			sectionInd = i
+			break
+		}
+	}
+	if sectionInd < 0 {
+		return nil, fmt.Errorf("%s has no RomFS section", p.Files[ncaInd].Name)
+	}
+	section, err := p.openNcaSection(ncaInd, sectionInd, keyset)
+	if err != nil {
+		return nil, err
+	}
+
+	romfsOffset := binary.LittleEndian.Uint64(section.fsHeader[ivfcLevel6Offset:])
+	romfsSize := binary.LittleEndian.Uint64(section.fsHeader[ivfcLevel6Size:])
+	if romfsOffset+romfsSize < romfsOffset || romfsOffset+romfsSize > section.size {
+		return nil, fmt.Errorf("%w: RomFS extends past end of section %d", ErrTruncated, sectionInd)
+	}
+	romfs := io.NewSectionReader(section, int64(romfsOffset), int64(romfsSize))
+
+	rh, err := readRomFSHeader(romfs)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := readRomFSTable(romfs, rh.dirMetaOffset, rh.dirMetaSize)
+	if err != nil {
+		return nil, err
+	}
+	files, err := readRomFSTable(romfs, rh.fileMetaOffset, rh.fileMetaSize)
+	if err != nil {
+		return nil, err
+	}
+
+	notFound := &fs.PathError{Op: "open", Path: romfsPath, Err: fs.ErrNotExist}
+	parts := strings.Split(strings.Trim(romfsPath, "/"), "/")
+	if parts[0] == "" {
+		return nil, notFound
+	}
+
+	// Walk the directories, starting at the root entry
+	var dir uint32
+	for _, name := range parts[:len(parts)-1] {
+		if uint64(dir)+romfsDirEntry > uint64(len(dirs)) {
+			return nil, fmt.Errorf("%w: directory entry 0x%X out of range", ErrTruncated, dir)
+		}
+		child := binary.LittleEndian.Uint32(dirs[dir+0x8:])
+		dir, err = findRomFSEntry(dirs, child, romfsDirEntry, name)
+		if err != nil {
+			return nil, err
+		}
+		if dir == romfsNone {
+			return nil, notFound
+		}
+	}
+
+	if uint64(dir)+romfsDirEntry > uint64(len(dirs)) {
+		return nil, fmt.Errorf("%w: directory entry 0x%X out of range", ErrTruncated, dir)
+	}
+	child := binary.LittleEndian.Uint32(dirs[dir+0xC:])
+	file, err := findRomFSEntry(files, child, romfsFileEntry, parts[len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+	if file == romfsNone {
+		return nil, notFound
+	}
+
+	dataOffset := binary.LittleEndian.Uint64(files[file+0x8:])
+	dataSize := binary.LittleEndian.Uint64(files[file+0x10:])
+	start := rh.dataOffset + dataOffset
+	if start < rh.dataOffset || start+dataSize < start || start+dataSize > romfsSize {
+		return nil, fmt.Errorf("%w: %s extends past end of RomFS", ErrTruncated, romfsPath)
+	}
+	n, err := allocSize(dataSize)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := romfs.ReadAt(buf, int64(start)); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("%w: %s", ErrTruncated, romfsPath)
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readRomFSHeader parses the RomFS header, checking the size field
+func readRomFSHeader(r io.ReaderAt) (romfsHeader, error) {
+	var rh romfsHeader
+	b := make([]byte, romfsHeaderSize)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("%w: RomFS header", ErrTruncated)
+		}
+		return rh, err
+	}
+	if size := binary.LittleEndian.Uint64(b); size != romfsHeaderSize {
+		return rh, fmt.Errorf("Invalid RomFS header size 0x%X", size)
+	}
+	rh.dirMetaOffset = binary.LittleEndian.Uint64(b[0x18:])
+	rh.dirMetaSize = binary.LittleEndian.Uint64(b[0x20:])
+	rh.fileMetaOffset = binary.LittleEndian.Uint64(b[0x38:])
+	rh.fileMetaSize = binary.LittleEndian.Uint64(b[0x40:])
+	rh.dataOffset = binary.LittleEndian.Uint64(b[0x48:])
+	return rh, nil
+}
+
+// readRomFSTable reads one of the RomFS metadata tables into memory
+func readRomFSTable(r io.ReaderAt, offset, size uint64) ([]byte, error) {
+	n, err := allocSize(size)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, int64(offset)); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("%w: RomFS metadata table", ErrTruncated)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// findRomFSEntry follows the sibling chain starting at first, returning the
+// offset of the entry called name or romfsNone. entrySize is the size of the
+// fixed portion of an entry, which is followed by the name.
+func findRomFSEntry(table []byte, first uint32, entrySize uint32, name string) (uint32, error) {
+	// Bound the walk so a looping sibling chain cannot hang
+	for cur, steps := first, 0; cur != romfsNone; steps++ {
+		if steps > len(table)/int(entrySize) || uint64(cur)+uint64(entrySize) > uint64(len(table)) {
+			return romfsNone, fmt.Errorf("%w: RomFS entry 0x%X out of range", ErrTruncated, cur)
+		}
+		nameSize := binary.LittleEndian.Uint32(table[cur+entrySize-4:])
+		nameStart := uint64(cur) + uint64(entrySize)
+		if nameStart+uint64(nameSize) > uint64(len(table)) {
+			return romfsNone, fmt.Errorf("%w: RomFS entry name at 0x%X", ErrTruncated, cur)
+		}
+		if string(table[nameStart:nameStart+uint64(nameSize)]) == name {
+			return cur, nil
+		}
+		cur = binary.LittleEndian.Uint32(table[cur+0x4:])
+	}
+	return romfsNone, nil
+}
@@ -0,0 +1,211 @@
+package gopfs0
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultSequentialBufferSize is used when SequentialBufferSize is zero
+const defaultSequentialBufferSize = 0x10000 // 64 KiB
+
+// sequentialReader returns a reader over size bytes at offset of r for callers
+// that only read front to back, and a function to call once it is no longer
+// needed. Reads from files are buffered through a buffer from the PFS0's
+// BufferPool so the small chunk reads done by NcaReader and the copy helpers
+// do not each become a read from the operating system.
+func (p *PFS0) sequentialReader(r io.ReaderAt, offset, size uint64) (io.Reader, func()) {
+	if _, inMemory := r.(*bytes.Reader); inMemory || p.SequentialBufferSize < 0 {
+		return io.NewSectionReader(r, int64(offset), int64(size)), func() {}
+	}
+	buf, release := p.copyBuffer()
+	return p.sequentialReaderWith(r, offset, size, buf), release
+}
+
+// sequentialReaderWith is like sequentialReader but buffers reads through
+// buf, which stays owned by the caller
+func (p *PFS0) sequentialReaderWith(r io.ReaderAt, offset, size uint64, buf []byte) io.Reader {
+	section := io.NewSectionReader(r, int64(offset), int64(size))
+	if _, inMemory := r.(*bytes.Reader); inMemory || p.SequentialBufferSize < 0 {
+		return section
+	}
+	return &pooledReader{src: section, buf: buf}
+}
+
+// NewPFS0FromFS opens name from fsys and reads its metadata. Files that
+// implement io.ReaderAt, such as those from os.DirFS, are read in place, and
+// files that implement io.Seeker are read by seeking. Anything else, such as
+// a file inside a zip, is copied out up front: into memory when it is at most
+// GzipMemoryLimit bytes and into a temporary file removed on Close otherwise.
+// Files read in place or by seeking are kept open until Close.
+func NewPFS0FromFS(fsys fs.FS, name string) (*PFS0, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		log.Print(err)
+		f.Close()
+		return nil, err
+	}
+
+	var r io.ReaderAt
+	switch file := f.(type) {
+	case io.ReaderAt:
+		r = file
+	case io.ReadSeeker:
+		r = &seekReaderAt{r: file}
+	default:
+		// The data is copied out, so the file is not needed afterwards
+		defer f.Close()
+		return newPFS0FromStream(f, fi.Size(), name, GzipMemoryLimit)
+	}
+
+	p, err := NewPFS0FromReaderAt(r, fi.Size(), name)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.closer = f
+	return p, nil
+}
+
+// seekReaderAt adapts an io.ReadSeeker to io.ReaderAt, serializing reads so
+// the shared position is not disturbed
+type seekReaderAt struct {
+	mu sync.Mutex
+	r  io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.r, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// OpenFileSeeker returns a reader over the file with the given index that
+// supports Seek, as needed by http.ServeContent. Seeks are clamped to the
+// bounds of the file. Close releases the handle on the NSP.
+func (p *PFS0) OpenFileSeeker(ind uint16) (io.ReadSeekCloser, error) {
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, err
+	}
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+	return &fileSeeker{
+		SectionReader: io.NewSectionReader(fileHandle, int64(offset), int64(size)),
+		close:         closeFile,
+	}, nil
+}
+
+// fileSeeker is the io.ReadSeekCloser returned by OpenFileSeeker
+type fileSeeker struct {
+	*io.SectionReader
+	close func() error
+}
+
+func (f *fileSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.SectionReader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos += offset
+	case io.SeekEnd:
+		pos = f.Size() + offset
+	default:
+		return 0, fmt.Errorf("Invalid whence %d", whence)
+	}
+	if pos < 0 {
+		pos = 0
+	} else if pos > f.Size() {
+		pos = f.Size()
+	}
+	return f.SectionReader.Seek(pos, io.SeekStart)
+}
+
+func (f *fileSeeker) Close() error {
+	return f.close()
+}
+
+// OpenFileCounted returns a reader over the file with the given index and a
+// counter of the bytes read from it so far, for progress displays that poll
+// at their own pace rather than receive callbacks. The counter is updated
+// atomically as the reader is read, so other goroutines must read it with
+// atomic.LoadInt64. Close releases the handle on the NSP.
+func (p *PFS0) OpenFileCounted(ind uint16) (io.ReadCloser, *int64, error) {
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	r, release := p.sequentialReader(fileHandle, offset, size)
+	c := &countedReader{r: r, close: func() error {
+		release()
+		return closeFile()
+	}}
+	return c, &c.n, nil
+}
+
+// countedReader is the io.ReadCloser returned by OpenFileCounted
+type countedReader struct {
+	r      io.Reader
+	n      int64
+	close  func() error
+	closed bool
+}
+
+func (c *countedReader) Read(b []byte) (int, error) {
+	if c.closed {
+		return 0, fs.ErrClosed
+	}
+	n, err := c.r.Read(b)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func (c *countedReader) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.close()
+}
+
+// FileReaderAtNamed returns a reader addressing the first file called name
+// from offset zero, along with its size, for handing a contained NCA or RomFS
+// to an external parser. The reader needs no closing. If there is no such
+// file the error wraps fs.ErrNotExist.
+func (p *PFS0) FileReaderAtNamed(name string) (io.ReaderAt, int64, error) {
+	ind, ok := p.FindFileFunc(func(f File) bool { return f.Name == name })
+	if !ok {
+		return nil, 0, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	offset, size, err := p.fileRegion(ind)
+	if err != nil {
+		return nil, 0, err
+	}
+	return io.NewSectionReader(p.readerAt(), int64(offset), int64(size)), int64(size), nil
+}
@@ -0,0 +1,202 @@
+package gopfs0
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+// seekOnlyFS serves the files of a MapFS without their ReadAt method, so
+// NewPFS0FromFS reads them through a seekReaderAt
+type seekOnlyFS struct {
+	fstest.MapFS
+}
+
+func (s seekOnlyFS) Open(name string) (fs.File, error) {
+	f, err := s.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return seekOnlyFile{f.(interface {
+		fs.File
+		io.Seeker
+	})}, nil
+}
+
+// seekOnlyFile exposes only the fs.File and io.Seeker methods of a file
+type seekOnlyFile struct {
+	f interface {
+		fs.File
+		io.Seeker
+	}
+}
+
+func (s seekOnlyFile) Stat() (fs.FileInfo, error)                { return s.f.Stat() }
+func (s seekOnlyFile) Read(b []byte) (int, error)                { return s.f.Read(b) }
+func (s seekOnlyFile) Seek(off int64, whence int) (int64, error) { return s.f.Seek(off, whence) }
+func (s seekOnlyFile) Close() error                              { return s.f.Close() }
+
+// readOnlyFS serves the files of a MapFS with only the fs.File methods, like
+// the files inside a zip
+type readOnlyFS struct {
+	fstest.MapFS
+}
+
+func (r readOnlyFS) Open(name string) (fs.File, error) {
+	f, err := r.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ fs.File }{f}, nil
+}
+
+func TestNewPFS0FromFSReadOnly(t *testing.T) {
+	files := concurrentFiles(2)
+	b := pfs0test.BuildFixture(files)
+	p, err := NewPFS0FromFS(readOnlyFS{fstest.MapFS{"fixture.nsp": {Data: b}}}, "fixture.nsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if _, ok := p.src.(*bytes.Reader); !ok {
+		t.Fatalf("source of a 0x%X byte file is %T, want it in memory", len(b), p.src)
+	}
+	for i, f := range p.Files {
+		var buf bytes.Buffer
+		if _, err := p.WriteFileTo(uint16(i), &buf); err != nil || !bytes.Equal(buf.Bytes(), files[f.Name]) {
+			t.Fatalf("WriteFileTo(%d) of %s does not match, err %v", i, f.Name, err)
+		}
+	}
+
+	// Past the memory limit the data goes to a temporary file instead
+	big, err := newPFS0FromStream(bytes.NewReader(b), int64(len(b)), "fixture.nsp", 0x100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, ok := big.src.(*os.File)
+	if !ok {
+		t.Fatalf("source over the limit is %T, want a temporary file", big.src)
+	}
+	if len(big.Files) != len(files) {
+		t.Fatalf("parsed %d files, want %d", len(big.Files), len(files))
+	}
+	if err := big.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmp.Name()); !os.IsNotExist(err) {
+		t.Fatalf("temporary file %s left behind: %v", tmp.Name(), err)
+	}
+}
+
+func TestConcurrentReadsOfTwoIndices(t *testing.T) {
+	files := concurrentFiles(2)
+	fixture := writeFixtureFile(t, files)
+
+	for _, tc := range []struct {
+		name string
+		open func(t *testing.T) *PFS0
+	}{
+		{"file", func(t *testing.T) *PFS0 {
+			p := NewPFS0(fixture)
+			if err := p.ReadMetadata(); err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}},
+		{"seeker", func(t *testing.T) *PFS0 {
+			b, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := NewPFS0FromFS(seekOnlyFS{fstest.MapFS{"fixture.nsp": {Data: b}}}, "fixture.nsp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := p.src.(*seekReaderAt); !ok {
+				t.Fatalf("source is %T, want *seekReaderAt", p.src)
+			}
+			return p
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.open(t)
+			defer p.Close()
+
+			// Both indices are read by every method at once, so a shared
+			// position would mix up the streams
+			readers := map[string]func(ind uint16) ([]byte, error){
+				"WriteFileTo": func(ind uint16) ([]byte, error) {
+					var buf bytes.Buffer
+					_, err := p.WriteFileTo(ind, &buf)
+					return buf.Bytes(), err
+				},
+				"NcaReader": func(ind uint16) ([]byte, error) {
+					c, err := p.NcaReader(ind)
+					if err != nil {
+						return nil, err
+					}
+					var out []byte
+					for chnk := range c {
+						if chnk.Err != nil {
+							return nil, chnk.Err
+						}
+						out = append(out, chnk.Content...)
+					}
+					return out, nil
+				},
+				"OpenFileSeeker": func(ind uint16) ([]byte, error) {
+					f, err := p.OpenFileSeeker(ind)
+					if err != nil {
+						return nil, err
+					}
+					defer f.Close()
+					// Read the second half first to move the position around
+					half := int64(p.Files[ind].Size / 2)
+					if _, err := f.Seek(half, io.SeekStart); err != nil {
+						return nil, err
+					}
+					tail, err := io.ReadAll(f)
+					if err != nil {
+						return nil, err
+					}
+					if _, err := f.Seek(0, io.SeekStart); err != nil {
+						return nil, err
+					}
+					head := make([]byte, half)
+					if _, err := io.ReadFull(f, head); err != nil {
+						return nil, err
+					}
+					return append(head, tail...), nil
+				},
+			}
+
+			var wg sync.WaitGroup
+			for method, read := range readers {
+				for round := 0; round < 4; round++ {
+					for i := range p.Files {
+						wg.Add(1)
+						go func(method string, read func(uint16) ([]byte, error), ind uint16) {
+							defer wg.Done()
+							name := p.Files[ind].Name
+							got, err := read(ind)
+							if err != nil {
+								t.Errorf("%s(%d): %v", method, ind, err)
+								return
+							}
+							if !bytes.Equal(got, files[name]) {
+								t.Errorf("%s(%d) of %s read the wrong bytes", method, ind, name)
+							}
+						}(method, read, uint16(i))
+					}
+				}
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package gopfs0
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errFreeSpaceUnsupported is returned by freeSpace on platforms where the
+// available space cannot be queried
+var errFreeSpaceUnsupported = errors.New("Free space cannot be determined on this platform")
+
+// ErrInsufficientSpace is returned when a destination does not have room for
+// the data about to be written to it
+var ErrInsufficientSpace = errors.New("Not enough free space")
+
+// EnsureSpace returns an error unless the filesystem holding destDir has room
+// for every file in the PFS0
+func (p *PFS0) EnsureSpace(destDir string) error {
+	return requireSpace(destDir, p.contentSize())
+}
+
+// CheckFreeSpace returns an error wrapping ErrInsufficientSpace if the
+// filesystem holding destDir cannot hold every file in the PFS0. Unlike
+// EnsureSpace it passes on platforms where free space cannot be queried, so it
+// is safe to call before every ExtractAll.
+func (p *PFS0) CheckFreeSpace(destDir string) error {
+	return checkSpace(destDir, p.contentSize())
+}
+
+// CheckCopySpace is CheckFreeSpace for copying the whole archive, header
+// included, into destDir
+func (p *PFS0) CheckCopySpace(destDir string) error {
+	return checkSpace(destDir, p.Size)
+}
+
+// contentSize returns the combined size of every file in the PFS0
+func (p *PFS0) contentSize() uint64 {
+	var needed uint64
+	for _, f := range p.Files {
+		needed += f.Size
+	}
+	return needed
+}
+
+// checkSpace is requireSpace, ignoring platforms without a free space query
+func checkSpace(dir string, needed uint64) error {
+	if err := requireSpace(dir, needed); err != nil && !errors.Is(err, errFreeSpaceUnsupported) {
+		return err
+	}
+	return nil
+}
+
+// requireSpace returns an error unless the filesystem holding dir has at
+// least needed bytes available
+func requireSpace(dir string, needed uint64) error {
+	available, err := freeSpace(dir)
+	if err != nil {
+		return err
+	}
+	if available < needed {
+		return fmt.Errorf("%w in %s. Need %s, %s available", ErrInsufficientSpace, dir, humanSize(needed), humanSize(available))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package gopfs0
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// SplitPartSize is the usual part size for split NSPs, just under the 4 GiB
+// FAT32 file size limit
+const SplitPartSize = 0xFFFF0000
+
+// WriteSplit writes the whole NSP into destDir as numbered parts (00, 01, ...)
+// of at most partSize bytes, cutting at partSize boundaries regardless of
+// where files start and end. destDir is created if needed and, where the
+// platform allows, marked with the archive attribute that Switch installers
+// use to recognize a split NSP. On other platforms set the attribute on the
+// SD card itself, e.g. with fatattr +a.
+func (p *PFS0) WriteSplit(destDir string, partSize int64) error {
+	if partSize <= 0 {
+		return fmt.Errorf("Invalid part size %d", partSize)
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
+	// Every part is copied through the one buffer
+	buf, release := p.copyBuffer()
+	defer release()
+	size := int64(p.Size)
+	for part, offset := 0, int64(0); offset < size || part == 0; part, offset = part+1, offset+partSize {
+		if part > 99 {
+			return fmt.Errorf("Part size %d needs more than 100 parts", partSize)
+		}
+		n := min(partSize, size-offset)
+		r := p.sequentialReaderWith(fileHandle, uint64(offset), uint64(n), buf)
+		if err := writePart(filepath.Join(destDir, fmt.Sprintf("%02d", part)), r, uint64(n)); err != nil {
+			return err
+		}
+	}
+	return setArchiveBit(destDir)
+}
+
+// writePart writes size bytes from r to a new file at path, failing with
+// ErrTruncated if r ends sooner
+func writePart(path string, r io.Reader, size uint64) error {
+	out, err := os.Create(path)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	_, err = copyExact(out, r, size, "part "+filepath.Base(path))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package gopfs0
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+func TestWriteSplit(t *testing.T) {
+	path := writeFixtureFile(t, concurrentFiles(3))
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPFS0(path)
+	if err := p.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "split.nsp")
+	const partSize = 0x7000
+	if err := p.WriteSplit(dir, partSize); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := (len(want) + partSize - 1) / partSize; len(entries) != n {
+		t.Fatalf("WriteSplit wrote %d parts, want %d", len(entries), n)
+	}
+	var joined []byte
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		joined = append(joined, b...)
+	}
+	if !bytes.Equal(joined, want) {
+		t.Fatal("joined parts do not match the NSP")
+	}
+}
+
+func TestWriteSplitShortSource(t *testing.T) {
+	b := pfs0test.BuildFixture(map[string][]byte{"a.nca": make([]byte, 0x100)})
+	// The source claims 0x100 more bytes than it holds, so the last part
+	// comes up short
+	p, err := NewPFS0FromReaderAt(bytes.NewReader(b), int64(len(b))+0x100, "short.nsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WriteSplit(t.TempDir(), 0x100); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("WriteSplit error = %v, want ErrTruncated", err)
+	}
+}
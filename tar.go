@@ -0,0 +1,63 @@
+package gopfs0
+
+import (
+	"archive/tar"
+	"io"
+	"sort"
+	"time"
+)
+
+// WriteTar writes every file in the PFS0 to w as a tar archive, in index
+// order. Entries are given the NSP's modification time when it has one.
+func (p *PFS0) WriteTar(w io.Writer) error {
+	modTime, err := p.sourceModTime()
+	if err != nil {
+		modTime = time.Unix(0, 0)
+	}
+	indices := make([]uint16, len(p.Files))
+	for i := range indices {
+		indices[i] = uint16(i)
+	}
+	return p.writeTar(w, indices, modTime, 0644)
+}
+
+// WriteTarDeterministic writes every file in the PFS0 to w as a tar archive
+// that depends only on the files' names and contents: entries are sorted by
+// name and have a modification time of the Unix epoch, uid and gid 0 and mode
+// 0444. Identical archives therefore produce byte-identical tars.
+func (p *PFS0) WriteTarDeterministic(w io.Writer) error {
+	indices := make([]uint16, len(p.Files))
+	for i := range indices {
+		indices[i] = uint16(i)
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		return p.Files[indices[a]].Name < p.Files[indices[b]].Name
+	})
+	return p.writeTar(w, indices, time.Unix(0, 0), 0444)
+}
+
+// writeTar writes the files with the given indices to w as a tar archive
+func (p *PFS0) writeTar(w io.Writer, indices []uint16, modTime time.Time, mode int64) error {
+	tw := tar.NewWriter(w)
+	for _, i := range indices {
+		f := p.Files[i]
+		if err := checkFileName(f.Name); err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.Name,
+			Size:     int64(f.Size),
+			Mode:     mode,
+			ModTime:  modTime,
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := p.WriteFileTo(i, tw); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
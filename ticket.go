@@ -0,0 +1,247 @@
+package gopfs0
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Offsets of fields inside the ticket body, which follows the signature block
+const (
+	tikIssuer            = 0x0
+	tikTitleKeyBlock     = 0x40
+	tikTitleKeyType      = 0x141
+	tikMasterKeyRevision = 0x145
+	tikTicketID          = 0x150
+	tikDeviceID          = 0x158
+	tikRightsID          = 0x160
+	tikAccountID         = 0x170
+	tikBodySize          = 0x180
+)
+
+// tikSignatureSizes maps a ticket signature type to the size of the signature and
+// the padding that follows it
+var tikSignatureSizes = map[uint32][2]int{
+	0x010000: {0x200, 0x3C}, // RSA-4096 SHA1
+	0x010001: {0x100, 0x3C}, // RSA-2048 SHA1
+	0x010002: {0x3C, 0x40},  // ECDSA SHA1
+	0x010003: {0x200, 0x3C}, // RSA-4096 SHA256
+	0x010004: {0x100, 0x3C}, // RSA-2048 SHA256
+	0x010005: {0x3C, 0x40},  // ECDSA SHA256
+}
+
+// ticketBody returns the portion of a ticket following its signature block
+func ticketBody(tik []byte) ([]byte, error) {
+	if len(tik) < 4 {
+		return nil, errors.New("Ticket too short to contain a signature type")
+	}
+	sigType := binary.LittleEndian.Uint32(tik[:4])
+	sizes, ok := tikSignatureSizes[sigType]
+	if !ok {
+		return nil, fmt.Errorf("Unknown ticket signature type 0x%X", sigType)
+	}
+	start := 4 + sizes[0] + sizes[1]
+	if len(tik) < start+tikBodySize {
+		return nil, fmt.Errorf("Ticket too short. Expected at least 0x%X bytes, got 0x%X", start+tikBodySize, len(tik))
+	}
+	return tik[start:], nil
+}
+
+// TicketInfo holds the fields of a ticket
+type TicketInfo struct {
+	SignatureType uint32
+	Issuer        string
+	// TitleKey is the encrypted title key of a common ticket. Personalized
+	// tickets store an RSA-encrypted key block that is not interpreted.
+	TitleKey [16]byte
+	// TitleKeyType is 0 for common tickets and 1 for personalized ones
+	TitleKeyType      byte
+	MasterKeyRevision byte
+	TicketID          uint64
+	DeviceID          uint64
+	RightsID          [16]byte
+	AccountID         uint32
+}
+
+// ParseTicketInfo decodes the fields of the ticket tik
+func ParseTicketInfo(tik []byte) (TicketInfo, error) {
+	body, err := ticketBody(tik)
+	if err != nil {
+		return TicketInfo{}, err
+	}
+	info := TicketInfo{
+		SignatureType:     binary.LittleEndian.Uint32(tik),
+		Issuer:            string(bytes.TrimRight(body[tikIssuer:tikIssuer+0x40], "\x00")),
+		TitleKeyType:      body[tikTitleKeyType],
+		MasterKeyRevision: body[tikMasterKeyRevision],
+		TicketID:          binary.LittleEndian.Uint64(body[tikTicketID:]),
+		DeviceID:          binary.LittleEndian.Uint64(body[tikDeviceID:]),
+		AccountID:         binary.LittleEndian.Uint32(body[tikAccountID:]),
+	}
+	copy(info.TitleKey[:], body[tikTitleKeyBlock:])
+	copy(info.RightsID[:], body[tikRightsID:])
+	return info, nil
+}
+
+// ReadTicketInfo reads and decodes the first ticket in the PFS0
+func (p *PFS0) ReadTicketInfo() (TicketInfo, error) {
+	tik, err := p.ReadTik()
+	if err != nil {
+		return TicketInfo{}, err
+	}
+	return ParseTicketInfo(tik)
+}
+
+// keyGenerationFirmware maps a master key revision to the first system
+// version that shipped it
+var keyGenerationFirmware = []string{
+	"1.0.0", "3.0.0", "3.0.1", "4.0.0", "5.0.0", "6.0.0", "6.2.0", "7.0.0",
+	"8.1.0", "9.0.0", "9.1.0", "12.1.0", "13.0.0", "14.0.0", "15.0.0",
+	"16.0.0", "17.0.0", "18.0.0", "19.0.0", "20.0.0",
+}
+
+// KeyGeneration returns the master key revision (the XX in master_key_XX)
+// needed for the ticket's title key
+func (t TicketInfo) KeyGeneration() int {
+	return int(t.MasterKeyRevision)
+}
+
+// FirmwareRequirement returns the minimum system version able to use the
+// ticket, such as "9.0.0+"
+func (t TicketInfo) FirmwareRequirement() string {
+	gen := t.KeyGeneration()
+	if gen >= len(keyGenerationFirmware) {
+		return fmt.Sprintf("Unknown (key generation %d)", gen)
+	}
+	return keyGenerationFirmware[gen] + "+"
+}
+
+// Ticket is a raw ticket as stored in the PFS0
+type Ticket struct {
+	raw []byte
+}
+
+// ReadTicket reads the first ticket in the PFS0
+func (p *PFS0) ReadTicket() (*Ticket, error) {
+	tik, err := p.ReadTik()
+	if err != nil {
+		return nil, err
+	}
+	return &Ticket{raw: tik}, nil
+}
+
+// Bytes returns the ticket as stored
+func (t *Ticket) Bytes() []byte {
+	return t.raw
+}
+
+// SignatureType returns the signature type at the start of the ticket, or 0
+// if the ticket is too short to hold one
+func (t *Ticket) SignatureType() uint32 {
+	if len(t.raw) < 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(t.raw)
+}
+
+// KeyGeneration returns the master key revision byte of the ticket, which
+// selects the title_kek_XX its title key is encrypted with. It is 0 if the
+// ticket is malformed.
+func (t *Ticket) KeyGeneration() byte {
+	body, err := ticketBody(t.raw)
+	if err != nil {
+		return 0
+	}
+	return body[tikMasterKeyRevision]
+}
+
+// DecryptTitleKey decrypts the title key of the first ticket in the PFS0 with
+// the title_kek_XX named by its key generation. Only common tickets are
+// supported, as personalized ones are encrypted for a single console.
+func (p *PFS0) DecryptTitleKey(keyset *Keyset) ([16]byte, error) {
+	t, err := p.ReadTicket()
+	if err != nil {
+		return [16]byte{}, err
+	}
+	body, err := ticketBody(t.raw)
+	if err != nil {
+		return [16]byte{}, err
+	}
+	return decryptTitleKey(body, t.KeyGeneration(), keyset)
+}
+
+// signatureValid reports whether the ticket's signature block is
+// structurally sound: a known signature type, a complete signature, padding
+// and body, zeroed padding and a signature that is not blank
+func (t *Ticket) signatureValid() bool {
+	sizes, ok := tikSignatureSizes[t.SignatureType()]
+	if !ok || len(t.raw) < 4+sizes[0]+sizes[1]+tikBodySize {
+		return false
+	}
+	sig := t.raw[4 : 4+sizes[0]]
+	padding := t.raw[4+sizes[0] : 4+sizes[0]+sizes[1]]
+	if !bytes.Equal(padding, make([]byte, len(padding))) {
+		return false
+	}
+	// Stubbed tickets from some repacking tools leave the signature blank
+	return !bytes.Equal(sig, make([]byte, len(sig))) && !bytes.Equal(sig, bytes.Repeat([]byte{0xFF}, len(sig)))
+}
+
+// TicketSignatureValid reports whether the signature block of the first
+// ticket in the PFS0 is structurally sound. The signature itself is not
+// verified, as that needs Nintendo's public keys.
+func (p *PFS0) TicketSignatureValid() (bool, error) {
+	t, err := p.ReadTicket()
+	if err != nil {
+		return false, err
+	}
+	return t.signatureValid(), nil
+}
+
+// TitleKeys returns the encrypted title key of every ticket in the PFS0,
+// keyed by the lower case hex of its rights ID
+func (p *PFS0) TitleKeys() (map[string][16]byte, error) {
+	keys := make(map[string][16]byte)
+	for i, f := range p.Files {
+		if !strings.HasSuffix(f.Name, ".tik") {
+			continue
+		}
+		tik, err := p.readFile(uint16(i))
+		if err != nil {
+			return nil, err
+		}
+		info, err := ParseTicketInfo(tik)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse %s: %w", f.Name, err)
+		}
+		keys[hex.EncodeToString(info.RightsID[:])] = info.TitleKey
+	}
+	return keys, nil
+}
+
+// WriteTitleKeysTSV writes the title keys from TitleKeys to w as one line per
+// ticket holding the rights ID and key in hex, separated by a tab and sorted
+// by rights ID
+func (p *PFS0) WriteTitleKeysTSV(w io.Writer) error {
+	keys, err := p.TitleKeys()
+	if err != nil {
+		return err
+	}
+	rightsIDs := make([]string, 0, len(keys))
+	for id := range keys {
+		rightsIDs = append(rightsIDs, id)
+	}
+	sort.Strings(rightsIDs)
+	for _, id := range rightsIDs {
+		key := keys[id]
+		if _, err := fmt.Fprintf(w, "%s\t%x\n", id, key[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
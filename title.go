@@ -0,0 +1,74 @@
+package gopfs0
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// titleIDBaseMask clears the bits that distinguish an update (0x800) or DLC
+// (0x1000 plus the DLC index) from its base application
+const titleIDBaseMask = ^uint64(0x1FFF)
+
+// TitleID returns the title ID of the PFS0's content. It is read from the
+// content meta when that is readable, and otherwise from the rights ID of the
+// ticket.
+func (p *PFS0) TitleID() (uint64, error) {
+	cnmt, cnmtErr := p.ReadCNMT()
+	if cnmtErr == nil {
+		if len(cnmt) < 8 {
+			return 0, fmt.Errorf("%w: CNMT is only 0x%X bytes", ErrTruncated, len(cnmt))
+		}
+		return binary.LittleEndian.Uint64(cnmt), nil
+	}
+	info, err := p.ReadTicketInfo()
+	if err != nil {
+		return 0, fmt.Errorf("Unable to determine title ID: %w", errors.Join(cnmtErr, err))
+	}
+	return binary.BigEndian.Uint64(info.RightsID[:8]), nil
+}
+
+// BaseTitleID returns the title ID of the application that the update or DLC
+// with title ID id belongs to. Application title IDs are returned unchanged.
+func BaseTitleID(id uint64) uint64 {
+	return id & titleIDBaseMask
+}
+
+// BaseTitleID returns the title ID of the application the PFS0's content
+// belongs to
+func (p *PFS0) BaseTitleID() (uint64, error) {
+	id, err := p.TitleID()
+	if err != nil {
+		return 0, err
+	}
+	return BaseTitleID(id), nil
+}
+
+// DetectVersion returns the title version recorded in the content meta, such
+// as 65536 for the first update. This is best effort: ok is false whenever
+// the version cannot be read, for instance when the .cnmt.nca is still
+// encrypted or there is no content meta at all.
+func (p *PFS0) DetectVersion() (version uint32, ok bool) {
+	cnmt, err := p.ReadCNMT()
+	if err != nil || len(cnmt) < 0xC {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(cnmt[0x8:]), true
+}
+
+// QuickScan reads just enough of the NSP at path to return its title ID,
+// size and file count, for indexing many files without keeping a PFS0 for
+// each. The title ID comes from the ticket's rights ID, or from the content
+// meta when there is no ticket.
+func QuickScan(path string) (titleID uint64, size uint64, fileCount int, err error) {
+	p := NewPFS0(path)
+	if err := p.ReadMetadata(); err != nil {
+		return 0, 0, 0, err
+	}
+	if info, err := p.ReadTicketInfo(); err == nil {
+		titleID = binary.BigEndian.Uint64(info.RightsID[:8])
+	} else if titleID, err = p.TitleID(); err != nil {
+		return 0, 0, 0, err
+	}
+	return titleID, p.Size, len(p.Files), nil
+}
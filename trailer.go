@@ -0,0 +1,130 @@
+package gopfs0
+
+import (
+	"bytes"
+	"io"
+)
+
+// TrailerKind identifies data found after the last file of a PFS0
+type TrailerKind int
+
+// Trailer kinds recognized by Trailer. No vendor or installer specific
+// trailers are recognized; they are reported as TrailerUnknown with their
+// bytes in Data for the caller to identify.
+const (
+	// TrailerNone means the file ends with the last file's data
+	TrailerNone TrailerKind = iota
+	// TrailerUnknown is data that was not recognized
+	TrailerUnknown
+	// TrailerPadding is a run of 0x00 or 0xFF bytes
+	TrailerPadding
+	// TrailerArchive is another container appended to the file, of any
+	// built in format or one added with RegisterMagic
+	TrailerArchive
+)
+
+func (k TrailerKind) String() string {
+	switch k {
+	case TrailerNone:
+		return "None"
+	case TrailerPadding:
+		return "Padding"
+	case TrailerArchive:
+		return "Archive"
+	}
+	return "Unknown"
+}
+
+// maxTrailerData is the largest trailer Trailer returns the bytes of
+const maxTrailerData = 0x100000 // 1 MiB
+
+// TrailerInfo describes the data following the last file
+type TrailerInfo struct {
+	Kind   TrailerKind
+	Offset uint64
+	Size   uint64
+	// Data holds the trailer bytes unless it is padding. It is nil for
+	// trailers larger than 1 MiB, whatever their kind; use TrailerBytes to
+	// read those.
+	Data []byte
+}
+
+// Trailer identifies any data stored after the end of the last file
+func (p *PFS0) Trailer() (TrailerInfo, error) {
+	end, err := p.contentEnd()
+	if err != nil {
+		return TrailerInfo{}, err
+	}
+	info := TrailerInfo{Offset: end, Size: p.Size - end}
+	if info.Size == 0 {
+		return info, nil
+	}
+
+	fileHandle, closeFile, err := p.open()
+	if err != nil {
+		return info, err
+	}
+	defer closeFile()
+	trailer := io.NewSectionReader(fileHandle, int64(info.Offset), int64(info.Size))
+
+	head := make([]byte, min(info.Size, 4))
+	if _, err := trailer.ReadAt(head, 0); err != nil {
+		return info, err
+	}
+	if _, ok := lookupFormat(string(head)); ok {
+		info.Kind = TrailerArchive
+	} else {
+		padding, err := isPadding(trailer, head[0])
+		if err != nil {
+			return info, err
+		}
+		if padding {
+			info.Kind = TrailerPadding
+			return info, nil
+		}
+		info.Kind = TrailerUnknown
+	}
+
+	if info.Size <= maxTrailerData {
+		info.Data = make([]byte, info.Size)
+		if _, err := trailer.ReadAt(info.Data, 0); err != nil {
+			return info, err
+		}
+	}
+	return info, nil
+}
+
+// isPadding reports whether r holds only 0x00 bytes or only 0xFF bytes,
+// deciding which from first
+func isPadding(r io.Reader, first byte) (bool, error) {
+	if first != 0x00 && first != 0xFF {
+		return false, nil
+	}
+	want := bytes.Repeat([]byte{first}, chunkSize)
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := r.Read(buf)
+		if !bytes.Equal(buf[:n], want[:n]) {
+			return false, nil
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// TrailerBytes returns all of the data stored after the end of the last file,
+// whatever its size or kind, or an empty slice when there is none
+func (p *PFS0) TrailerBytes() ([]byte, error) {
+	end, err := p.contentEnd()
+	if err != nil {
+		return nil, err
+	}
+	if end == p.Size {
+		return []byte{}, nil
+	}
+	return p.readAt(end, p.Size-end)
+}
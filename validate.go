@@ -0,0 +1,31 @@
+package gopfs0
+
+import "errors"
+
+// ErrOverlapsHeader is returned when a file's content would start inside the
+// header, entry table or string table
+var ErrOverlapsHeader = errors.New("File overlaps the PFS0 header")
+
+// FileOverlapsHeader reports whether the content of the file with the given
+// index would start before the end of the string table. File offsets are
+// relative to the end of the string table, so this only happens when a
+// crafted StartOffset wraps around. Out of range indices report false.
+func (p *PFS0) FileOverlapsHeader(ind uint16) bool {
+	if int(ind) >= len(p.Files) {
+		return false
+	}
+	return p.contentBase()+p.Files[ind].StartOffset < p.contentBase()
+}
+
+// Validate checks that every file lies within the archive and after its
+// header, returning all of the problems found joined together, or nil if
+// there are none
+func (p *PFS0) Validate() error {
+	var errs []error
+	for i := range p.Files {
+		if _, _, err := p.fileRegion(uint16(i)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
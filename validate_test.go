@@ -0,0 +1,35 @@
+package gopfs0
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+func TestOffsetWrappingIntoHeader(t *testing.T) {
+	b := pfs0test.BuildFixture(map[string][]byte{"a.nca": make([]byte, 0x20), "b.tik": make([]byte, 0x10)})
+	// An offset of -0x10 wraps around to point back into the string table
+	setEntry(b, 0, ^uint64(0)-0xF, 0x10)
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.FileOverlapsHeader(0) {
+		t.Error("FileOverlapsHeader(0) = false for a wrapped offset")
+	}
+	if p.FileOverlapsHeader(1) {
+		t.Error("FileOverlapsHeader(1) = true for an intact entry")
+	}
+	if _, err := p.WriteFileTo(0, io.Discard); !errors.Is(err, ErrOverlapsHeader) {
+		t.Errorf("WriteFileTo error = %v, want ErrOverlapsHeader", err)
+	}
+	if err := p.Validate(); !errors.Is(err, ErrOverlapsHeader) {
+		t.Errorf("Validate error = %v, want ErrOverlapsHeader", err)
+	}
+	if _, err := p.WriteFileTo(1, io.Discard); err != nil {
+		t.Errorf("WriteFileTo of the intact entry = %v", err)
+	}
+}
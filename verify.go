@@ -0,0 +1,139 @@
+package gopfs0
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// VerifyReport describes how a directory differs from the PFS0 it was
+// extracted from. File names are as stored in the PFS0.
+type VerifyReport struct {
+	// Missing lists files in the PFS0 that are not in the directory
+	Missing []string
+	// Extra lists files in the directory that are not in the PFS0
+	Extra []string
+	// Mismatched lists files whose size, or hash when checked, differs
+	Mismatched []string
+}
+
+// OK reports whether the directory matched the PFS0 exactly
+func (r VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// VerifyAgainstDir compares dir with the PFS0, checking that every file was
+// extracted into it with the right size and that it holds nothing else.
+// Files are looked for under the names ExtractAll writes them as, after
+// DefaultSanitizeName. Contents are not compared; use VerifyAgainstDirHashed
+// for that.
+func (p *PFS0) VerifyAgainstDir(dir string) (VerifyReport, error) {
+	return p.verifyAgainstDir(dir, false)
+}
+
+// VerifyAgainstDirHashed is like VerifyAgainstDir but also compares the
+// SHA-256 of every file of the right size with that of the file in the PFS0
+func (p *PFS0) VerifyAgainstDirHashed(dir string) (VerifyReport, error) {
+	return p.verifyAgainstDir(dir, true)
+}
+
+// verifyAgainstDir implements VerifyAgainstDir, hashing files when checkHash
+// is set
+func (p *PFS0) verifyAgainstDir(dir string, checkHash bool) (VerifyReport, error) {
+	var report VerifyReport
+	inArchive := make(map[string]bool, len(p.Files))
+	for i, f := range p.Files {
+		name := DefaultSanitizeName(f.Name)
+		inArchive[name] = true
+		if err := checkFileName(name); err != nil {
+			return report, err
+		}
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			report.Missing = append(report.Missing, f.Name)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		if !fi.Mode().IsRegular() || uint64(fi.Size()) != f.Size {
+			report.Mismatched = append(report.Mismatched, f.Name)
+			continue
+		}
+		if !checkHash {
+			continue
+		}
+		match, err := p.matchesOnDisk(uint16(i), filepath.Join(dir, name))
+		if err != nil {
+			return report, err
+		}
+		if !match {
+			report.Mismatched = append(report.Mismatched, f.Name)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Println(err)
+		return report, err
+	}
+	for _, e := range entries {
+		if !inArchive[e.Name()] {
+			report.Extra = append(report.Extra, e.Name())
+		}
+	}
+	sort.Strings(report.Extra)
+	return report, nil
+}
+
+// matchesOnDisk reports whether the file at path has the same SHA-256 as the
+// file with the given index
+func (p *PFS0) matchesOnDisk(ind uint16, path string) (bool, error) {
+	want, err := p.HashFile(ind)
+	if err != nil {
+		return false, err
+	}
+	got, err := hashPath(path)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(got, want[:]), nil
+}
+
+// hashPath returns the SHA-256 of the file at path
+func hashPath(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// VerifyIncremental hashes every file with HashFile and returns, in index
+// order, the names whose SHA-256 differs from prior or that prior does not
+// list. Names in prior that are no longer in the PFS0 are not reported.
+func (p *PFS0) VerifyIncremental(prior map[string][32]byte) (changed []string, err error) {
+	for i, f := range p.Files {
+		digest, err := p.HashFile(uint16(i))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to hash %s: %w", f.Name, err)
+		}
+		if want, ok := prior[f.Name]; !ok || want != digest {
+			changed = append(changed, f.Name)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,88 @@
+package gopfs0
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nosmokingbandit/gopfs0/pfs0test"
+)
+
+func TestVerifyIncremental(t *testing.T) {
+	prior := make(map[string][32]byte)
+	for name, content := range map[string]string{
+		"a.nca": "unchanged", "b.nca": "before", "c.tik": "removed", "d.nca": "also unchanged",
+	} {
+		prior[name] = sha256.Sum256([]byte(content))
+	}
+
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(pfs0test.BuildFixture(map[string][]byte{
+		"a.nca":   []byte("unchanged"),
+		"b.nca":   []byte("after"),
+		"d.nca":   []byte("also unchanged"),
+		"e.cnmt":  []byte("added"),
+		"0.cert":  []byte("added first"),
+		"f.empty": nil,
+	})); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := p.VerifyIncremental(prior)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changed and added files are reported in index order, and the removed
+	// c.tik is not reported at all
+	if want := []string{"0.cert", "b.nca", "e.cnmt", "f.empty"}; !reflect.DeepEqual(changed, want) {
+		t.Fatalf("VerifyIncremental = %q, want %q", changed, want)
+	}
+
+	current := make(map[string][32]byte)
+	for i, f := range p.Files {
+		if current[f.Name], err = p.HashFile(uint16(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if changed, err := p.VerifyIncremental(current); err != nil || changed != nil {
+		t.Fatalf("VerifyIncremental against its own digests = %q, %v", changed, err)
+	}
+}
+
+func TestVerifyAgainstDirSanitizedNames(t *testing.T) {
+	files := map[string][]byte{"a:b.tik": []byte("ticket"), "con.nca": []byte("nca"), "plain.cert": []byte("cert")}
+	p := &PFS0{}
+	if err := p.ReadMetadataFromBytes(pfs0test.BuildFixture(files)); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := p.ExtractAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, verify := range []func(string) (VerifyReport, error){p.VerifyAgainstDir, p.VerifyAgainstDirHashed} {
+		report, err := verify(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.OK() {
+			t.Fatalf("report of a fresh extraction = %+v", report)
+		}
+	}
+
+	// Reports keep the names stored in the PFS0
+	if err := os.Remove(filepath.Join(dir, "_con.nca")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a_b.tik"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := p.VerifyAgainstDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VerifyReport{Missing: []string{"con.nca"}, Mismatched: []string{"a:b.tik"}}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("report = %+v, want %+v", report, want)
+	}
+}
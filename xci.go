@@ -0,0 +1,79 @@
+package gopfs0
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// Offsets inside the XCI header, relative to where the header starts
+	xciMagicOffset     = 0x100
+	xciRootHFS0Offset  = 0x130
+	xciGameCardCert    = 0x7000
+	xciGameCardCertLen = 0x200
+
+	// xciKeyAreaSize is the size of the key area that full dumps store before
+	// the XCI header
+	xciKeyAreaSize = 0x1000
+	// xciInitialDataLen is the size of the initial data at the start of the
+	// key area
+	xciInitialDataLen = 0x200
+)
+
+// errNotXCI is returned by gamecard accessors when the file is not an XCI
+var errNotXCI = errors.New("Not an XCI gamecard image")
+
+// detectXCI checks r for an XCI header, either at the start of the file or
+// after a key area, and if found points BaseOffset at the root HFS0 partition
+func (p *PFS0) detectXCI(r io.ReaderAt) bool {
+	for _, start := range []uint64{0, xciKeyAreaSize} {
+		hdr := make([]byte, 0x140)
+		if _, err := r.ReadAt(hdr, int64(start)); err != nil {
+			continue
+		}
+		if string(hdr[xciMagicOffset:xciMagicOffset+4]) != "HEAD" {
+			continue
+		}
+		root := start + binary.LittleEndian.Uint64(hdr[xciRootHFS0Offset:xciRootHFS0Offset+8])
+		if root < start || root > p.Size {
+			return false
+		}
+		p.xci = true
+		p.xciOffset = start
+		p.BaseOffset = root
+		return true
+	}
+	return false
+}
+
+// IsXCI reports whether the file is a gamecard image rather than an NSP
+func (p *PFS0) IsXCI() bool {
+	return p.xci
+}
+
+// GameCardCert returns the 0x200 byte gamecard certificate of an XCI
+func (p *PFS0) GameCardCert() ([]byte, error) {
+	if !p.xci {
+		return nil, errNotXCI
+	}
+	offset := p.xciOffset + xciGameCardCert
+	if offset+xciGameCardCertLen > p.Size {
+		return nil, fmt.Errorf("%w: XCI is too small to hold a gamecard certificate", ErrTruncated)
+	}
+	return p.readAt(offset, xciGameCardCertLen)
+}
+
+// GameCardInitialData returns the 0x200 byte initial data used to
+// authenticate the gamecard. It is stored in the key area at the start of
+// full dumps, so images without a key area fail.
+func (p *PFS0) GameCardInitialData() ([]byte, error) {
+	if !p.xci {
+		return nil, errNotXCI
+	}
+	if p.xciOffset < xciKeyAreaSize {
+		return nil, errors.New("XCI has no key area holding the initial data")
+	}
+	return p.readAt(p.xciOffset-xciKeyAreaSize, xciInitialDataLen)
+}
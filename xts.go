@@ -0,0 +1,61 @@
+package gopfs0
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+)
+
+// decryptXTS decrypts data in place with AES-128-XTS as consecutive sectors of
+// sectorSize bytes, the first being sector. key holds the data key followed by
+// the tweak key. Nintendo stores the sector number in the tweak big-endian,
+// unlike standard XTS.
+func decryptXTS(key, data []byte, sectorSize int, sector uint64) error {
+	if len(key) != 0x20 {
+		return fmt.Errorf("XTS key must be 0x20 bytes, got 0x%X", len(key))
+	}
+	if sectorSize%aes.BlockSize != 0 || len(data)%sectorSize != 0 {
+		return fmt.Errorf("XTS data must be whole 0x%X byte sectors", sectorSize)
+	}
+	dataCipher, err := aes.NewCipher(key[:0x10])
+	if err != nil {
+		return err
+	}
+	tweakCipher, err := aes.NewCipher(key[0x10:])
+	if err != nil {
+		return err
+	}
+
+	var tweak [aes.BlockSize]byte
+	for off := 0; off < len(data); off += sectorSize {
+		tweak = [aes.BlockSize]byte{}
+		binary.BigEndian.PutUint64(tweak[8:], sector)
+		tweakCipher.Encrypt(tweak[:], tweak[:])
+
+		for b := off; b < off+sectorSize; b += aes.BlockSize {
+			block := data[b : b+aes.BlockSize]
+			xorBlock(block, tweak[:])
+			dataCipher.Decrypt(block, block)
+			xorBlock(block, tweak[:])
+
+			// Multiply the tweak by x in GF(2^128)
+			carry := tweak[15] >> 7
+			for j := 15; j > 0; j-- {
+				tweak[j] = tweak[j]<<1 | tweak[j-1]>>7
+			}
+			tweak[0] <<= 1
+			if carry != 0 {
+				tweak[0] ^= 0x87
+			}
+		}
+		sector++
+	}
+	return nil
+}
+
+// xorBlock xors src into dst
+func xorBlock(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}